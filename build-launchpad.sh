@@ -21,7 +21,7 @@ go install fyne.io/fyne/v2/cmd/fyne@latest
 
 # Build with icon
 GOOS=windows GOARCH=amd64 CGO_ENABLED=1 CC=x86_64-w64-mingw32-gcc \
-  go build -ldflags="-H windowsgui" -o LaunchPad.exe launchpad.go
+  go build -ldflags="-H windowsgui" -o LaunchPad.exe launchpad.go graphics.go browser.go ini.go throttle.go verifycache.go safepath.go selfupdate.go backup.go localstate.go verify.go repair.go diskcheck.go transaction.go auth.go signin.go httpclient.go profile.go profileselect.go gamedetect.go gamefolderselect.go httpcache.go logging.go support.go launchprofile.go serverconfig.go multibox.go supervise.go crashreport.go hooks.go serverstatus.go eqhost.go packs.go packselect.go launchertheme.go configfile.go redact.go packlist.go repairreport.go
 
 if [ -f "LaunchPad.exe" ]; then
     echo "✓ LaunchPad.exe built successfully"
@@ -5,6 +5,14 @@ echo "  Building Simple EQ Patcher"
 echo "════════════════════════════════════════"
 echo ""
 
+# Source files for each client program (both are package main in client/)
+PATCHER_SOURCES="patcher.go throttle.go safepath.go verifycache.go verify.go localstate.go backup.go diskcheck.go transaction.go auth.go httpclient.go profile.go gamedetect.go httpcache.go logging.go ini.go launchprofile.go hooks.go eqhost.go packs.go configfile.go redact.go"
+LAUNCHPAD_SOURCES="launchpad.go graphics.go browser.go ini.go throttle.go verifycache.go safepath.go selfupdate.go backup.go localstate.go verify.go repair.go diskcheck.go transaction.go auth.go signin.go httpclient.go profile.go profileselect.go gamedetect.go gamefolderselect.go httpcache.go logging.go support.go launchprofile.go serverconfig.go multibox.go supervise.go crashreport.go hooks.go serverstatus.go eqhost.go packs.go packselect.go launchertheme.go configfile.go redact.go packlist.go repairreport.go"
+
+# Tests build with the patcher's sources, so they cover the code both programs
+# share, plus LaunchPad files that don't need fyne
+TEST_SOURCES="$PATCHER_SOURCES packlist.go repairreport.go"
+
 # Build server manifest builder (Linux)
 echo "Building server manifest-builder..."
 cd server
@@ -15,13 +23,39 @@ else
     echo "✗ Failed to build server tool"
     exit 1
 fi
+go build -o crash-intake/crash-intake ./crash-intake
+if [ $? -eq 0 ]; then
+    echo "✓ Crash report intake built: server/crash-intake/crash-intake"
+else
+    echo "✗ Failed to build crash report intake"
+    exit 1
+fi
+cd ..
+
+# Run tests
+echo ""
+echo "Running tests..."
+cd server
+go test ./...
+if [ $? -ne 0 ]; then
+    echo "✗ Server tests failed"
+    exit 1
+fi
+cd ../client
+go test $TEST_SOURCES *_test.go
+if [ $? -eq 0 ]; then
+    echo "✓ Tests passed"
+else
+    echo "✗ Client tests failed"
+    exit 1
+fi
 cd ..
 
 # Build CLI patcher (Windows) - fallback
 echo ""
 echo "Building CLI patcher for Windows..."
 cd client
-GOOS=windows GOARCH=amd64 go build -o patcher.exe patcher.go
+GOOS=windows GOARCH=amd64 go build -o patcher.exe $PATCHER_SOURCES
 if [ $? -eq 0 ]; then
     echo "✓ CLI patcher built: client/patcher.exe"
 else
@@ -50,7 +84,7 @@ mv rsrc_windres.syso rsrc.syso
 # Build with mingw
 echo "  Compiling LaunchPad.exe..."
 GOOS=windows GOARCH=amd64 CGO_ENABLED=1 CC=x86_64-w64-mingw32-gcc \
-  go build -ldflags="-H windowsgui -s -w" -o LaunchPad.exe launchpad.go graphics.go browser.go ini.go
+  go build -ldflags="-H windowsgui -s -w" -o LaunchPad.exe $LAUNCHPAD_SOURCES
 
 if [ $? -eq 0 ]; then
     echo "✓ GUI LaunchPad built: client/LaunchPad.exe"
@@ -63,7 +97,7 @@ cd ..
 echo ""
 echo "Building CLI patcher for Linux (testing)..."
 cd client
-go build -o patcher-linux patcher.go
+go build -o patcher-linux $PATCHER_SOURCES
 if [ $? -eq 0 ]; then
     echo "✓ Linux patcher built: client/patcher-linux"
 else
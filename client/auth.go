@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"simple-eq-patcher/client/secret"
+)
+
+// AuthConfig holds credentials for a private patch server. A token means
+// bearer auth, otherwise a username means basic auth. Secrets are stored
+// sealed (see the secret package); values typed into the config file by
+// hand are sealed the next time it is loaded.
+type AuthConfig struct {
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"password,omitempty"`
+	Token        string `json:"token,omitempty"`
+	RefreshURL   string `json:"refresh_url,omitempty"` // absolute, or relative to server_url
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// errAuthRequired is returned when the server still answers 401 after any
+// token refresh
+var errAuthRequired = errors.New("the patch server requires you to sign in")
+
+// authMu guards config.Auth, which can change while the news and manifest
+// are being downloaded
+var authMu sync.Mutex
+
+// saveCredentials, if set, writes the config file after a token refresh or
+// sign-in changes the stored credentials
+var saveCredentials func() error
+
+// usesBearerAuth reports whether the server is set up for tokens rather than
+// a username and password
+func usesBearerAuth() bool {
+	authMu.Lock()
+	defer authMu.Unlock()
+	return config.Auth != nil && (config.Auth.Token != "" || config.Auth.RefreshURL != "")
+}
+
+// sealCredentials seals any secrets stored in plain text. Returns true if
+// anything changed, so the caller can save the config.
+func sealCredentials(auth *AuthConfig) bool {
+	if auth == nil {
+		return false
+	}
+
+	changed := false
+	for _, value := range []*string{&auth.Password, &auth.Token, &auth.RefreshToken} {
+		if *value != "" && !secret.IsSealed(*value) {
+			*value = secret.Seal(*value)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// setCredentials stores new credentials, e.g. from the sign-in prompt, and
+// saves them
+func setCredentials(username, password, token string) error {
+	authMu.Lock()
+	if config.Auth == nil {
+		config.Auth = &AuthConfig{}
+	}
+	if token != "" {
+		config.Auth.Token = secret.Seal(token)
+	} else {
+		config.Auth.Username = username
+		config.Auth.Password = secret.Seal(password)
+	}
+	authMu.Unlock()
+
+	if saveCredentials != nil {
+		return saveCredentials()
+	}
+	return nil
+}
+
+// openSecret returns a stored secret, or "" if it can't be read back (e.g.
+// the config was copied from another PC)
+func openSecret(stored string) string {
+	plain, err := secret.Open(stored)
+	if err != nil {
+		slog.Warn("could not read stored credentials", "error", err)
+		return ""
+	}
+	return plain
+}
+
+// newGetRequest builds a GET request with the configured credentials
+func newGetRequest(rawURL string) (*http.Request, error) {
+	return newRequest(http.MethodGet, rawURL, nil)
+}
+
+// newRequest builds a request with the configured credentials
+func newRequest(method, rawURL string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	authMu.Lock()
+	defer authMu.Unlock()
+	if auth := config.Auth; auth != nil {
+		if auth.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+openSecret(auth.Token))
+		} else if auth.Username != "" {
+			req.SetBasicAuth(auth.Username, openSecret(auth.Password))
+		}
+	}
+	return req, nil
+}
+
+// httpGet fetches a URL from the patch server with the configured
+// credentials. On a 401 the bearer token is refreshed once and the request
+// retried; if the server still refuses, errAuthRequired is returned.
+func httpGet(rawURL string) (*http.Response, error) {
+	return httpGetWith(rawURL, nil)
+}
+
+// httpGetWith is httpGet with extra request headers
+func httpGetWith(rawURL string, header http.Header) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newGetRequest(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		start := time.Now()
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			slog.Warn("http request failed", "url", rawURL, "error", err, "duration", time.Since(start))
+			return nil, err
+		}
+		if resp.StatusCode >= 400 {
+			slog.Warn("http error", "url", rawURL, "status", resp.StatusCode, "duration", time.Since(start))
+		} else {
+			slog.Debug("http request", "url", rawURL, "status", resp.StatusCode, "duration", time.Since(start))
+		}
+		if resp.StatusCode != http.StatusUnauthorized {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		if attempt > 0 {
+			return nil, errAuthRequired
+		}
+		if err := refreshToken(); err != nil {
+			if err != errNoRefresh {
+				slog.Warn("token refresh failed", "error", err)
+			}
+			return nil, errAuthRequired
+		}
+	}
+}
+
+// httpGetAnonymous is httpGetWith without the configured credentials, for
+// URLs on hosts other than the patch server
+func httpGetAnonymous(rawURL string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		slog.Warn("http request failed", "url", rawURL, "error", err)
+		return nil, err
+	}
+	slog.Debug("http request", "url", rawURL, "status", resp.StatusCode)
+	return resp, nil
+}
+
+// sameHost reports whether two URLs point at the same host and port
+func sameHost(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	return errA == nil && errB == nil && ua.Scheme == ub.Scheme && strings.EqualFold(ua.Host, ub.Host)
+}
+
+var errNoRefresh = errors.New("no token refresh endpoint configured")
+
+// refreshToken swaps the refresh token for a new bearer token, using the
+// OAuth 2 refresh_token grant
+func refreshToken() error {
+	authMu.Lock()
+	auth := config.Auth
+	if auth == nil || auth.RefreshURL == "" || auth.RefreshToken == "" {
+		authMu.Unlock()
+		return errNoRefresh
+	}
+	endpoint := auth.RefreshURL
+	if !strings.Contains(endpoint, "://") {
+		endpoint = strings.TrimRight(config.ServerURL, "/") + "/" + strings.TrimLeft(endpoint, "/")
+	}
+	refresh := openSecret(auth.RefreshToken)
+	authMu.Unlock()
+
+	resp, err := httpClient.PostForm(endpoint, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refresh},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.AccessToken == "" {
+		return fmt.Errorf("no access_token in response")
+	}
+
+	authMu.Lock()
+	auth.Token = secret.Seal(result.AccessToken)
+	if result.RefreshToken != "" {
+		auth.RefreshToken = secret.Seal(result.RefreshToken)
+	}
+	authMu.Unlock()
+
+	if saveCredentials != nil {
+		if err := saveCredentials(); err != nil {
+			slog.Warn("could not save refreshed token", "error", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+const (
+	backupDir          = ".patcher-backup"
+	backupIndexFile    = ".patcher-backup/index.json"
+	defaultMaxBackupMB = 1024
+)
+
+// BackupEntry is a stock game file that a patch replaced
+type BackupEntry struct {
+	Path    string `json:"path"`    // game file path (forward slashes)
+	Stored  string `json:"stored"`  // where the original is kept, relative to the game folder
+	Version string `json:"version"` // manifest version that replaced it
+	Size    int64  `json:"size"`
+	Time    string `json:"time"`
+}
+
+// backupIndex lists everything in the .patcher-backup store. Originals are
+// kept under a folder named after the manifest version that replaced them.
+type backupIndex struct {
+	Files []BackupEntry `json:"files"`
+}
+
+// loadBackupIndex loads the backup index, returning an empty one if there
+// are no backups yet
+func loadBackupIndex() *backupIndex {
+	idx := &backupIndex{Files: []BackupEntry{}}
+
+	data, err := os.ReadFile(backupIndexFile)
+	if err != nil {
+		return idx
+	}
+	if err := json.Unmarshal(data, idx); err != nil {
+		slog.Warn("could not read backup index", "file", backupIndexFile, "error", err)
+		idx.Files = []BackupEntry{}
+	}
+
+	return idx
+}
+
+func (idx *backupIndex) save() error {
+	if len(idx.Files) == 0 {
+		os.Remove(backupIndexFile)
+		return nil
+	}
+
+	err := os.MkdirAll(backupDir, 0755)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(backupIndexFile, data, 0644)
+}
+
+// find returns the backup of path, or nil if there isn't one
+func (idx *backupIndex) find(path string) *BackupEntry {
+	key := filepath.ToSlash(path)
+	for i := range idx.Files {
+		if idx.Files[i].Path == key {
+			return &idx.Files[i]
+		}
+	}
+	return nil
+}
+
+func (idx *backupIndex) remove(path string) {
+	key := filepath.ToSlash(path)
+	kept := idx.Files[:0]
+	for _, entry := range idx.Files {
+		if entry.Path != key {
+			kept = append(kept, entry)
+		}
+	}
+	idx.Files = kept
+}
+
+// totalSize is the combined size of all backed up files
+func (idx *backupIndex) totalSize() int64 {
+	var total int64
+	for _, entry := range idx.Files {
+		total += entry.Size
+	}
+	return total
+}
+
+// maxBackupBytes returns the backup store size cap from max_backup_mb.
+// 0 uses the default, a negative value turns backups off.
+func maxBackupBytes() int64 {
+	mb := config.MaxBackupMB
+	if mb == 0 {
+		mb = defaultMaxBackupMB
+	}
+	if mb < 0 {
+		return 0
+	}
+	return int64(mb) * 1024 * 1024
+}
+
+// wouldBackUp reports whether overwriting path would move it into the backup
+// store: backups are on, it isn't a file the patcher installed itself (listed
+// in the local manifest), and it hasn't been backed up already
+func (idx *backupIndex) wouldBackUp(path string, local *Manifest) bool {
+	if maxBackupBytes() == 0 {
+		return false
+	}
+
+	key := filepath.ToSlash(path)
+	if idx.find(key) != nil {
+		return false
+	}
+	if local != nil {
+		for _, file := range local.Files {
+			if filepath.ToSlash(file.Path) == key {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// unbackedOriginals lists the stock files among files that would be
+// overwritten without a backup because the store would go over
+// max_backup_mb. Callers warn the player about them before updating, since
+// those originals can't be restored afterwards.
+func unbackedOriginals(files []FileEntry) []string {
+	maxBytes := maxBackupBytes()
+	if maxBytes == 0 {
+		return nil // Backups are off, so none are expected
+	}
+
+	idx := loadBackupIndex()
+	local := loadLocalManifest()
+	total := idx.totalSize()
+
+	var unbacked []string
+	for _, file := range files {
+		info, err := os.Stat(file.Path)
+		if err != nil || info.IsDir() || !idx.wouldBackUp(file.Path, local) {
+			continue
+		}
+		if total+info.Size() > maxBytes {
+			unbacked = append(unbacked, filepath.ToSlash(file.Path))
+			continue
+		}
+		total += info.Size()
+	}
+	return unbacked
+}
+
+// backupOriginal moves a stock file that is about to be overwritten into the
+// backup store, if wouldBackUp says it should and the store isn't full.
+// Files that don't fit are the ones unbackedOriginals reported beforehand.
+// Returns true if the file was moved, so the caller can put it back if the
+// download fails.
+func (idx *backupIndex) backupOriginal(path, version string, local *Manifest) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false, nil // Nothing to back up
+	}
+
+	key := filepath.ToSlash(path)
+	if !idx.wouldBackUp(key, local) {
+		return false, nil
+	}
+
+	maxBytes := maxBackupBytes()
+	if idx.totalSize()+info.Size() > maxBytes {
+		slog.Warn("backup store is full, not backing up", "path", key, "cap_mb", maxBytes/1024/1024)
+		return false, nil
+	}
+
+	stored := filepath.ToSlash(filepath.Join(backupDir, backupVersionDir(version), key))
+	err = os.MkdirAll(filepath.Dir(stored), 0755)
+	if err != nil {
+		return false, err
+	}
+	err = os.Rename(path, stored)
+	if err != nil {
+		return false, fmt.Errorf("could not back up %s: %v", key, err)
+	}
+
+	idx.Files = append(idx.Files, BackupEntry{
+		Path:    key,
+		Stored:  stored,
+		Version: version,
+		Size:    info.Size(),
+		Time:    time.Now().Format(time.RFC3339),
+	})
+	return true, idx.save()
+}
+
+// putBack moves a backed up original back into place and drops it from
+// the store
+func (idx *backupIndex) putBack(path string) error {
+	entry := idx.find(path)
+	if entry == nil {
+		return fmt.Errorf("no backup of %s", path)
+	}
+	if err := checkManifestPath(entry.Stored); err != nil {
+		return err
+	}
+
+	err := os.Rename(entry.Stored, entry.Path)
+	if err != nil {
+		// Fallback: copy over the patched file
+		if err := copyFile(entry.Stored, entry.Path); err != nil {
+			return fmt.Errorf("could not restore %s: %v", entry.Path, err)
+		}
+		os.Remove(entry.Stored)
+	}
+
+	idx.remove(path)
+	return idx.save()
+}
+
+// restoreOriginals copies every backed up original back over the patched
+// file. The backups are kept, so the patch can be applied again later.
+func restoreOriginals() (int, error) {
+	idx := loadBackupIndex()
+
+	restored := 0
+	for _, entry := range idx.Files {
+		if checkManifestPath(entry.Path) != nil || checkManifestPath(entry.Stored) != nil {
+			continue
+		}
+		if err := copyFile(entry.Stored, entry.Path); err != nil {
+			return restored, fmt.Errorf("could not restore %s: %v", entry.Path, err)
+		}
+		restored++
+	}
+
+	return restored, nil
+}
+
+// uninstallPatch puts the game folder back the way it was before patching:
+// originals are restored, files the patch added are removed, and the
+// patcher's local state is cleared
+func uninstallPatch() (restored, removed int, err error) {
+	idx := loadBackupIndex()
+
+	local := loadLocalManifest()
+	if local != nil {
+		for _, file := range local.Files {
+			if checkManifestPath(file.Path) != nil || isLauncherBinary(file.Path) {
+				continue
+			}
+
+			if idx.find(file.Path) != nil {
+				if err := idx.putBack(file.Path); err != nil {
+					return restored, removed, err
+				}
+				restored++
+				continue
+			}
+
+			err := os.Remove(file.Path)
+			if err == nil {
+				removed++
+			} else if !os.IsNotExist(err) {
+				return restored, removed, fmt.Errorf("could not remove %s: %v", file.Path, err)
+			}
+		}
+	}
+
+	// Anything left in the store (e.g. from a manifest we no longer track)
+	for len(idx.Files) > 0 {
+		entry := idx.Files[0]
+		if checkManifestPath(entry.Path) != nil || checkManifestPath(entry.Stored) != nil {
+			idx.remove(entry.Path)
+			continue
+		}
+		if err := idx.putBack(entry.Path); err != nil {
+			return restored, removed, err
+		}
+		restored++
+	}
+
+	os.RemoveAll(backupDir)
+	os.Remove(localManifestFile)
+	os.Remove(verifyCacheFile)
+	os.RemoveAll(httpCacheDir)
+
+	return restored, removed, nil
+}
+
+var unsafeVersionChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// backupVersionDir turns a manifest version into a folder name
+func backupVersionDir(version string) string {
+	dir := unsafeVersionChars.ReplaceAllString(version, "_")
+	if dir == "" || dir == "." || dir == ".." {
+		dir = "unversioned"
+	}
+	return dir
+}
+
+// copyFile copies src to dst, keeping src's permissions
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Config is patcher-config.json, shared by LaunchPad and the CLI patcher.
+// Settings only one of them uses are ignored by the other.
+type Config struct {
+	ServerURL     string `json:"server_url"`
+	ServerName    string `json:"server_name"`
+	LauncherTitle string `json:"launcher_title"`
+	WebsiteURL    string `json:"website_url"`
+	WebsiteLabel  string `json:"website_label"`
+	GameExe       string `json:"game_exe"`
+	GameArgs      string `json:"game_args"`
+	GameDir       string `json:"game_dir,omitempty"` // absolute, or relative to the folder holding the config file
+
+	LoginServer    string `json:"login_server,omitempty"`     // host[:port] of the login server, for eqhost.txt and LaunchPad's status line
+	LoginCheckPort int    `json:"login_check_port,omitempty"` // TCP port to probe instead of login_server's port
+	StatusURL      string `json:"status_url,omitempty"`       // optional JSON status, absolute or relative to server_url
+
+	EQHost []string `json:"eqhost,omitempty"` // login servers for eqhost.txt; default is login_server
+
+	MaxDownloadKbps int `json:"max_download_kbps,omitempty"` // 0 = unlimited
+	MaxBackupMB     int `json:"max_backup_mb,omitempty"`     // 0 = default, -1 = no backups
+
+	Auth *AuthConfig `json:"auth,omitempty"` // private patch servers only
+
+	Profiles      []ServerProfile `json:"profiles,omitempty"`       // e.g. live and test servers
+	ActiveProfile string          `json:"active_profile,omitempty"` // last selected in LaunchPad
+
+	LaunchProfiles      []LaunchProfile `json:"launch_profiles,omitempty"`       // named game_args alternatives
+	ActiveLaunchProfile string          `json:"active_launch_profile,omitempty"` // last selected in LaunchPad
+
+	Hooks *HookConfig `json:"hooks,omitempty"` // commands run before/after patching and before launch
+
+	Multibox     *MultiboxConfig `json:"multibox,omitempty"`      // last multi-box launch
+	StayResident bool            `json:"stay_resident,omitempty"` // keep LaunchPad open while playing to catch crashes
+
+	Proxy    string `json:"proxy,omitempty"`     // http://, https:// or socks5:// proxy; default is HTTP_PROXY etc.
+	CABundle string `json:"ca_bundle,omitempty"` // extra trusted CA certificates (PEM)
+	SPKIPin  string `json:"spki_pin,omitempty"`  // base64 SHA-256 of the server's public key, comma separated
+
+	LogLevel string `json:"log_level,omitempty"` // debug, info (default), warn or error; see the logs folder
+}
+
+// A config file written by a newer version, or edited by hand, can hold
+// keys this version doesn't know. Saving a Config as-is would drop them, so
+// they are carried over from the file on disk.
+
+// writeConfigFile saves cfg to path as indented JSON, keeping any top-level
+// keys in the existing file that Config doesn't have
+func writeConfigFile(path string, cfg *Config) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	var existing map[string]json.RawMessage
+	if old, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(old, &existing) // An unreadable file is simply replaced
+	}
+	known := jsonFieldNames(reflect.TypeOf(*cfg))
+	unknown := []string{}
+	for key := range existing {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+
+	if len(unknown) > 0 {
+		var b bytes.Buffer
+		b.Write(bytes.TrimSuffix(data, []byte("}")))
+		for i, key := range unknown {
+			if i > 0 || len(data) > 2 {
+				b.WriteByte(',')
+			}
+			name, _ := json.Marshal(key)
+			b.Write(name)
+			b.WriteByte(':')
+			b.Write(existing[key])
+		}
+		b.WriteByte('}')
+		data = b.Bytes()
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, out.Bytes())
+}
+
+// jsonFieldNames lists the JSON keys of struct type t
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteConfigFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string // "" = no file yet
+		cfg      Config
+		want     map[string]interface{}
+	}{
+		{
+			name: "new file",
+			cfg:  Config{ServerURL: "http://patch.example.com"},
+			want: map[string]interface{}{"server_url": "http://patch.example.com"},
+		},
+		{
+			name:     "unknown keys kept",
+			existing: `{"server_url": "http://old", "future_setting": {"a": 1}, "note": "hand edited"}`,
+			cfg:      Config{ServerURL: "http://new"},
+			want: map[string]interface{}{
+				"server_url":     "http://new",
+				"future_setting": map[string]interface{}{"a": 1.0},
+				"note":           "hand edited",
+			},
+		},
+		{
+			name:     "cleared known key dropped",
+			existing: `{"server_url": "http://old", "game_dir": "C:\\EQ"}`,
+			cfg:      Config{ServerURL: "http://old"},
+			want:     map[string]interface{}{"server_url": "http://old"},
+		},
+		{
+			name:     "invalid file replaced",
+			existing: `{"server_url": `,
+			cfg:      Config{ServerURL: "http://new"},
+			want:     map[string]interface{}{"server_url": "http://new"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "patcher-config.json")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := writeConfigFile(path, &tt.cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("saved file is not valid JSON: %v\n%s", err, data)
+			}
+			// Only compare keys that aren't empty defaults of Config
+			for key, value := range got {
+				if value == "" {
+					delete(got, key)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("saved %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+// After a crash the player can send a report to the server team, if the
+// server publishes crash_report_url in server-config.json. Nothing is sent
+// without the player agreeing first.
+
+const crashLogTailBytes = 512 * 1024 // how much of launchpad.log goes in a report
+
+// crashReportInfo is report.json in a crash report
+type crashReportInfo struct {
+	Created         string `json:"created"`
+	ExitCode        string `json:"exit_code"`
+	SessionSeconds  int    `json:"session_seconds"`
+	LaunchProfile   string `json:"launch_profile,omitempty"`
+	ServerProfile   string `json:"server_profile,omitempty"`
+	ServerURL       string `json:"server_url"`
+	ManifestVersion string `json:"manifest_version"`
+	OS              string `json:"os"`
+}
+
+// crashReportURL is where the server wants crash reports, "" if it doesn't
+func crashReportURL() string {
+	serverConfig, err := downloadServerConfig(config.ServerURL)
+	if err != nil {
+		return ""
+	}
+	return serverURLFor(config.ServerURL, serverConfig.CrashReportURL)
+}
+
+// confirmSendCrashReport tells the player what a report contains and sends
+// it if they agree
+func confirmSendCrashReport(win fyne.Window, reportURL string, session *gameSession) {
+	dialog.ShowConfirm("Send Crash Report",
+		fmt.Sprintf("Send a crash report to %s?\n\nIt contains dbg.txt, any crash dumps, eqclient.ini, LaunchPad's recent log,\nthe game version and the exit code. No passwords are included.", serverDisplayName()),
+		func(send bool) {
+			if !send {
+				slog.Info("crash report declined")
+				return
+			}
+			go func() {
+				statusLabel.SetText("Sending crash report...")
+				id, err := sendCrashReport(reportURL, session)
+				if err != nil {
+					slog.Error("crash report upload failed", "url", reportURL, "error", err)
+					showRunningStatus()
+					showError(win, fmt.Sprintf("Could not send the crash report:\n\n%v", err))
+					return
+				}
+				slog.Info("crash report sent", "id", id)
+				statusLabel.SetText("✓ Crash report sent - thank you!")
+			}()
+		}, win)
+}
+
+// sendCrashReport uploads a report for session and returns the id the
+// server gave it
+func sendCrashReport(reportURL string, session *gameSession) (string, error) {
+	body, err := buildCrashReport(session)
+	if err != nil {
+		return "", err
+	}
+
+	// Credentials only go to the patch server itself
+	newReq := http.NewRequest
+	if sameHost(reportURL, config.ServerURL) {
+		newReq = newRequest
+	}
+	req, err := newReq(http.MethodPost, reportURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/zip")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("server returned status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	var result struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return result.ID, nil
+}
+
+// buildCrashReport zips report.json, the session's crash files (dbg.txt and
+// crash dumps, as in Save Crash Files), eqclient.ini and the end of
+// launchpad.log
+func buildCrashReport(session *gameSession) ([]byte, error) {
+	info := crashReportInfo{
+		Created:        time.Now().UTC().Format(time.RFC3339),
+		ExitCode:       session.describeExit(),
+		SessionSeconds: int(session.Duration.Seconds()),
+		LaunchProfile:  session.Launch,
+		ServerProfile:  activeProfileName(),
+		ServerURL:      config.ServerURL,
+		OS:             runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if local := loadLocalManifest(); local != nil {
+		info.ManifestVersion = local.Version
+	}
+	infoData, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if err := addBytesToZip(zw, "report.json", infoData); err != nil {
+		return nil, err
+	}
+	for _, path := range crashArtifacts(session.Started) {
+		name := filepath.Base(filepath.Dir(path)) + "/" + filepath.Base(path)
+		if err := addFileToZip(zw, path, name); err != nil {
+			return nil, err
+		}
+	}
+	if err := addFileToZip(zw, filepath.Join(gameFolder, "eqclient.ini"), "eqclient.ini"); err != nil {
+		return nil, err
+	}
+	logTail, err := readTail(filepath.Join(launcherDir, logDirName, "launchpad.log"), crashLogTailBytes)
+	if err == nil {
+		if err := addBytesToZip(zw, "launchpad.log", logTail); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readTail returns up to the last n bytes of a file
+func readTail(path string, n int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > n {
+		if _, err := file.Seek(-n, io.SeekEnd); err != nil {
+			return nil, err
+		}
+	}
+	return io.ReadAll(file)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"simple-eq-patcher/client/diskspace"
+)
+
+// planDiskSpace returns the bytes an update will need on the game volume.
+// Every file is staged before anything is replaced, and the files being
+// replaced stay on disk until the update is committed, so the peak is the
+// full size of the new files.
+func planDiskSpace(toDownload []FileEntry) int64 {
+	var needed int64
+	for _, file := range toDownload {
+		needed += file.Size
+	}
+	return needed
+}
+
+// checkDiskSpace refuses an update that won't fit on the game volume, before
+// anything is downloaded. If free space can't be determined the update is
+// allowed to go ahead.
+func checkDiskSpace(toDownload []FileEntry) error {
+	needed := planDiskSpace(toDownload)
+	if needed == 0 {
+		return nil
+	}
+
+	dir, err := filepath.Abs(".")
+	if err != nil {
+		dir = "."
+	}
+	free, err := diskspace.Available(dir)
+	if err != nil {
+		slog.Warn("could not check free disk space", "error", err)
+		return nil
+	}
+
+	if uint64(needed) > free {
+		slog.Warn("not enough disk space", "dir", dir, "needed", needed, "free", free)
+		return fmt.Errorf("Not enough free disk space to apply this update.\n\nNeeded: %s\nAvailable: %s (on the drive with %s)\n\nFree up at least %s, for example by emptying the Recycle Bin or uninstalling programs you don't use, then try again.",
+			formatBytes(needed), formatBytes(int64(free)), dir, formatBytes(needed-int64(free)))
+	}
+
+	return nil
+}
+
+// formatBytes formats a byte count for display
+func formatBytes(bytes int64) string {
+	switch {
+	case bytes >= 1024*1024*1024:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/1024/1024/1024)
+	case bytes >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/1024/1024)
+	case bytes >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/1024)
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
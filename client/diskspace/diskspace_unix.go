@@ -0,0 +1,15 @@
+//go:build !windows
+
+package diskspace
+
+import "syscall"
+
+// Available returns the number of bytes free for the current user on the
+// volume that holds path
+func Available(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
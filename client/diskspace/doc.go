@@ -0,0 +1,2 @@
+// Package diskspace reports free disk space for the volume holding a path.
+package diskspace
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// eqhost.txt tells the game which login server to use. A copy pointing at
+// another server is the most common reason players can't log in, so the
+// launcher checks it before every launch and rewrites it from the config.
+
+const (
+	eqhostFile       = "eqhost.txt"
+	eqhostBackupFile = "eqhost.txt.bak" // the file as it was before the last rewrite
+	defaultLoginPort = 5998             // RoF2 login server port
+)
+
+// loginAddress is a login server address with the default port added if it
+// has none
+func loginAddress(loginServer string) string {
+	if _, _, err := net.SplitHostPort(loginServer); err == nil {
+		return loginServer
+	}
+	return net.JoinHostPort(loginServer, strconv.Itoa(defaultLoginPort))
+}
+
+// eqhostServers lists the login servers eqhost.txt should name: eqhost if
+// set, else login_server. None means eqhost.txt is left alone.
+func eqhostServers(cfg *Config) []string {
+	hosts := cfg.EQHost
+	if len(hosts) == 0 && cfg.LoginServer != "" {
+		hosts = []string{cfg.LoginServer}
+	}
+	servers := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if host = strings.TrimSpace(host); host != "" {
+			servers = append(servers, loginAddress(host))
+		}
+	}
+	return servers
+}
+
+// parseEQHost returns the login servers listed in an eqhost.txt, and
+// whether it uses the old "[LoginServer] Host=" layout rather than the
+// RoF2 "[Login Servers] { "host:port" }" one
+func parseEQHost(data string) (servers []string, legacy bool) {
+	section := ""
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+		case section == "login servers" && strings.HasPrefix(line, `"`):
+			servers = append(servers, strings.Trim(line, `"`))
+		case section == "loginserver":
+			key, value, ok := strings.Cut(line, "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "host") {
+				servers = append(servers, strings.TrimSpace(value))
+				legacy = true
+			}
+		}
+	}
+	return servers, legacy
+}
+
+// formatEQHost writes an eqhost.txt naming servers, in the layout the
+// existing file used
+func formatEQHost(servers []string, legacy bool) string {
+	var b strings.Builder
+	if legacy {
+		b.WriteString("[LoginServer]\r\n")
+		for _, server := range servers {
+			b.WriteString("Host=" + server + "\r\n")
+		}
+		return b.String()
+	}
+	for _, section := range []string{"Registration Servers", "Login Servers"} {
+		b.WriteString("[" + section + "]\r\n{\r\n")
+		for _, server := range servers {
+			b.WriteString(`"` + server + `"` + "\r\n")
+		}
+		b.WriteString("}\r\n")
+	}
+	return b.String()
+}
+
+// sameServers compares two login server lists, ignoring case
+func sameServers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ensureEQHost makes the eqhost.txt in dir name servers, rewriting it if it
+// names anything else. The previous file is kept as eqhost.txt.bak.
+// Returns true if the file was rewritten.
+func ensureEQHost(dir string, servers []string) (bool, error) {
+	if len(servers) == 0 {
+		return false, nil
+	}
+	path := filepath.Join(dir, eqhostFile)
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("could not read %s: %v", eqhostFile, err)
+	}
+	current, legacy := parseEQHost(string(data))
+	normalized := make([]string, 0, len(current))
+	for _, server := range current {
+		if server = strings.TrimSpace(server); server != "" {
+			normalized = append(normalized, loginAddress(server))
+		}
+	}
+	if sameServers(normalized, servers) {
+		return false, nil
+	}
+
+	if err == nil {
+		if err := copyFile(path, filepath.Join(dir, eqhostBackupFile)); err != nil {
+			return false, fmt.Errorf("could not back up %s: %v", eqhostFile, err)
+		}
+	}
+	if err := writeFileAtomic(path, []byte(formatEQHost(servers, legacy))); err != nil {
+		return false, fmt.Errorf("could not write %s: %v", eqhostFile, err)
+	}
+	slog.Info("eqhost.txt rewritten", "path", path, "was", current, "now", servers)
+	return true, nil
+}
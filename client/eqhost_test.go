@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseEQHost(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		want       []string
+		wantLegacy bool
+	}{
+		{
+			name: "rof2",
+			data: "[Registration Servers]\r\n{\r\n\"login.example.com:5998\"\r\n}\r\n[Login Servers]\r\n{\r\n\"login.example.com:5998\"\r\n\"backup.example.com:5999\"\r\n}\r\n",
+			want: []string{"login.example.com:5998", "backup.example.com:5999"},
+		},
+		{
+			name:       "legacy",
+			data:       "[LoginServer]\nHost=login.example.com:5998\nhost = backup.example.com\n",
+			want:       []string{"login.example.com:5998", "backup.example.com"},
+			wantLegacy: true,
+		},
+		{
+			name: "section names ignore case and spaces",
+			data: "[ login servers ]\n{\n  \"login.example.com\"  \n}\n",
+			want: []string{"login.example.com"},
+		},
+		{
+			name: "empty",
+			data: "",
+		},
+	}
+	for _, tt := range tests {
+		got, legacy := parseEQHost(tt.data)
+		if !reflect.DeepEqual(got, tt.want) || legacy != tt.wantLegacy {
+			t.Errorf("%s: parseEQHost() = %q, %v; want %q, %v", tt.name, got, legacy, tt.want, tt.wantLegacy)
+		}
+	}
+}
+
+func TestFormatEQHost(t *testing.T) {
+	servers := []string{"login.example.com:5998", "backup.example.com:5999"}
+	tests := []struct {
+		name   string
+		legacy bool
+		want   string
+	}{
+		{
+			name: "rof2",
+			want: "[Registration Servers]\r\n{\r\n\"login.example.com:5998\"\r\n\"backup.example.com:5999\"\r\n}\r\n" +
+				"[Login Servers]\r\n{\r\n\"login.example.com:5998\"\r\n\"backup.example.com:5999\"\r\n}\r\n",
+		},
+		{
+			name:   "legacy",
+			legacy: true,
+			want:   "[LoginServer]\r\nHost=login.example.com:5998\r\nHost=backup.example.com:5999\r\n",
+		},
+	}
+	for _, tt := range tests {
+		got := formatEQHost(servers, tt.legacy)
+		if got != tt.want {
+			t.Errorf("%s: formatEQHost() = %q, want %q", tt.name, got, tt.want)
+		}
+		// What we write must read back the same
+		parsed, legacy := parseEQHost(got)
+		if !reflect.DeepEqual(parsed, servers) || legacy != tt.legacy {
+			t.Errorf("%s: round trip = %q, %v", tt.name, parsed, legacy)
+		}
+	}
+}
+
+func TestEnsureEQHost(t *testing.T) {
+	servers := []string{"login.example.com:5998"}
+	tests := []struct {
+		name        string
+		existing    string // "" = no eqhost.txt
+		wantRewrite bool
+	}{
+		{"missing", "", true},
+		{"already right", "[LoginServer]\nHost=login.example.com:5998\n", false},
+		{"default port left out", "[LoginServer]\nHost=LOGIN.example.com\n", false},
+		{"other server", "[LoginServer]\nHost=other.example.com:5998\n", true},
+		{"other port", "[LoginServer]\nHost=login.example.com:5999\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, eqhostFile)
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rewritten, err := ensureEQHost(dir, servers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rewritten != tt.wantRewrite {
+				t.Errorf("ensureEQHost() = %v, want %v", rewritten, tt.wantRewrite)
+			}
+			data, _ := os.ReadFile(path)
+			if got, _ := parseEQHost(string(data)); rewritten && !reflect.DeepEqual(got, servers) {
+				t.Errorf("eqhost.txt names %q, want %q", got, servers)
+			}
+			_, err = os.Stat(filepath.Join(dir, eqhostBackupFile))
+			if wantBackup := rewritten && tt.existing != ""; (err == nil) != wantBackup {
+				t.Errorf("%s exists: %v, want %v", eqhostBackupFile, err == nil, wantBackup)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Folder names players commonly install the RoF2 client under
+var commonGameFolderNames = []string{
+	"EverQuest",
+	"EverQuest RoF2",
+	"RoF2",
+	"EQ",
+	"EQEmu",
+	`Games\EverQuest`,
+	`Games\EverQuest RoF2`,
+	`Games\RoF2`,
+}
+
+// gameFolderCandidates lists the places an RoF2 client is commonly
+// installed. The Daybreak live client is left out on purpose: patching it
+// with emulator files would break it.
+func gameFolderCandidates() []string {
+	dirs := []string{}
+	for _, drive := range []string{"C:", "D:", "E:"} {
+		for _, name := range commonGameFolderNames {
+			dirs = append(dirs, drive+`\`+name)
+		}
+	}
+
+	for _, env := range []string{"ProgramFiles(x86)", "ProgramFiles"} {
+		if base := os.Getenv(env); base != "" {
+			dirs = append(dirs,
+				filepath.Join(base, "EverQuest"),
+				filepath.Join(base, "EverQuest RoF2"),
+			)
+		}
+	}
+
+	if home := os.Getenv("USERPROFILE"); home != "" {
+		for _, parent := range []string{"", "Desktop", "Documents", "Games"} {
+			for _, name := range []string{"EverQuest", "EverQuest RoF2", "RoF2"} {
+				dirs = append(dirs, filepath.Join(home, parent, name))
+			}
+		}
+	}
+
+	return dirs
+}
+
+// detectGameFolders returns the candidate folders that contain gameExe
+func detectGameFolders(gameExe string) []string {
+	found := []string{}
+	seen := make(map[string]bool)
+	for _, dir := range gameFolderCandidates() {
+		key := strings.ToLower(filepath.Clean(dir))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if isGameFolder(dir, gameExe) {
+			found = append(found, dir)
+		}
+	}
+	return found
+}
+
+// isGameFolder reports whether dir contains gameExe
+func isGameFolder(dir, gameExe string) bool {
+	info, err := os.Stat(filepath.Join(dir, gameExe))
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// needsGameFolder reports whether the player has to tell us where the game
+// is: no game_dir is set and the game isn't next to LaunchPad
+func needsGameFolder() bool {
+	if p := findProfile(rootConfig.Profiles, rootConfig.ActiveProfile); p != nil && p.GameDir != "" {
+		return false
+	}
+	return rootConfig.GameDir == "" && !isGameFolder(launcherDir, config.GameExe)
+}
+
+// startUpdateCheck makes sure we know where the game is, then finishes any
+// interrupted update and checks for updates
+func startUpdateCheck(win fyne.Window) {
+	if needsGameFolder() {
+		chooseGameFolder(win, true, func() { startUpdateCheck(win) })
+		return
+	}
+
+	recoverInterruptedUpdate(win)
+	checkForUpdates(win, false)
+}
+
+// storeGameDir switches to dir as the game folder of the active profile (or
+// the top-level game_dir without profiles) and saves it. Nothing changes if
+// dir can't be used.
+func storeGameDir(dir string) error {
+	gameDir := &rootConfig.GameDir
+	if p := findProfile(rootConfig.Profiles, rootConfig.ActiveProfile); p != nil {
+		gameDir = &p.GameDir
+	}
+	previous := *gameDir
+
+	*gameDir = dir
+	if err := activateProfile(rootConfig.ActiveProfile); err != nil {
+		*gameDir = previous
+		return err
+	}
+	profileErr = nil
+	return saveConfig(rootConfig)
+}
+
+// chooseGameFolder asks where the game is installed, offering any folders
+// found in the usual places. On first run, dismissing it keeps using
+// LaunchPad's own folder. done runs in the background once a folder is in
+// use; it doesn't run if the player cancels.
+func chooseGameFolder(win fyne.Window, firstRun bool, done func()) {
+	statusLabel.SetText("Where is EverQuest installed?")
+	progressBar.Hide()
+
+	found := detectGameFolders(config.GameExe)
+	folderSelect := widget.NewSelect(found, nil)
+	if len(found) > 0 {
+		folderSelect.SetSelected(found[0])
+	} else {
+		folderSelect.PlaceHolder = "(none found - use Browse...)"
+	}
+
+	browseButton := widget.NewButton("Browse...", func() {
+		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
+			if err != nil || uri == nil {
+				return
+			}
+			folderSelect.Options = append(folderSelect.Options, uri.Path())
+			folderSelect.SetSelected(uri.Path())
+		}, win)
+	})
+
+	message := fmt.Sprintf("%s was not found next to LaunchPad.\nChoose the folder your EverQuest (RoF2) client is installed in:", config.GameExe)
+	if !firstRun {
+		message = "Choose the folder your EverQuest (RoF2) client is installed in:"
+	}
+	content := container.NewVBox(
+		widget.NewLabel(message),
+		container.NewBorder(nil, nil, nil, browseButton, folderSelect),
+	)
+
+	dismiss := "Cancel"
+	if firstRun {
+		dismiss = "Use LaunchPad Folder"
+	}
+
+	d := dialog.NewCustomConfirm("Game Folder", "Use This Folder", dismiss, content, func(ok bool) {
+		if !ok {
+			if firstRun {
+				// Don't ask again - the game lives next to LaunchPad
+				storeGameDir(".")
+				go done()
+			}
+			return
+		}
+
+		dir := folderSelect.Selected
+		if dir == "" || !isGameFolder(dir, config.GameExe) {
+			showError(win, fmt.Sprintf("%s was not found in %s", config.GameExe, dir))
+			chooseGameFolder(win, firstRun, done)
+			return
+		}
+		if err := storeGameDir(dir); err != nil {
+			showError(win, fmt.Sprintf("Could not use %s:\n\n%v", dir, err))
+			chooseGameFolder(win, firstRun, done)
+			return
+		}
+		go done()
+	}, win)
+	d.Resize(fyne.NewSize(520, 0))
+	d.Show()
+}
@@ -136,16 +136,8 @@ func getAvailableResolutions() []string {
 }
 
 func showGraphicsDialog(win fyne.Window) {
-	// Get the directory where the launcher is located
-	launcherPath, err := os.Executable()
-	if err != nil {
-		dialog.ShowError(fmt.Errorf("Could not determine launcher location: %v", err), win)
-		return
-	}
-	launcherDir := filepath.Dir(launcherPath)
-
-	// Load current settings from eqclient.ini in the same directory
-	iniPath := filepath.Join(launcherDir, "eqclient.ini")
+	// Load current settings from eqclient.ini in the game folder
+	iniPath := filepath.Join(gameFolder, "eqclient.ini")
 	ini, err := LoadINI(iniPath)
 	if err != nil {
 		dialog.ShowError(fmt.Errorf("Failed to load graphics settings: %v", err), win)
@@ -475,19 +467,12 @@ func showCompatibilityWizard(win fyne.Window) {
 }
 
 func applyCompatibilityFix(fixType string) error {
-	// Get the directory where the launcher is located
-	launcherPath, err := os.Executable()
-	if err != nil {
-		return fmt.Errorf("could not determine launcher location: %v", err)
-	}
-	launcherDir := filepath.Dir(launcherPath)
-
-	// Get full path to eqgame.exe in the same directory as launcher
-	exePath := filepath.Join(launcherDir, config.GameExe)
+	// Get full path to eqgame.exe in the game folder
+	exePath := filepath.Join(gameFolder, config.GameExe)
 
 	// Check if eqgame.exe exists
 	if _, err := os.Stat(exePath); os.IsNotExist(err) {
-		return fmt.Errorf("game executable not found: %s\n\nMake sure %s is in the same folder as LaunchPad.exe", exePath, config.GameExe)
+		return fmt.Errorf("game executable not found: %s\n\nMake sure %s is in the game folder", exePath, config.GameExe)
 	}
 
 	// Build registry command based on fix type
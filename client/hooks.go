@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Hooks are commands the server admin wants run at fixed points, e.g.
+// regenerating eqhost.txt before launch or copying a UI skin after a patch.
+// They run in the game folder, with their output going to the log.
+
+// Hook stages
+const (
+	hookPrePatch  = "pre_patch"  // before an update is downloaded
+	hookPostPatch = "post_patch" // after an update is installed
+	hookPreLaunch = "pre_launch" // before the game is started
+)
+
+const (
+	defaultHookTimeout = 60        // seconds
+	hookOutputShown    = 2048      // bytes of output shown when a hook fails
+	hookOutputLogged   = 64 * 1024 // bytes of output written to the log
+)
+
+// Hook is one command in the "hooks" section of patcher-config.json
+type Hook struct {
+	Name     string   `json:"name,omitempty"`
+	Command  []string `json:"command"`                   // program and arguments, e.g. ["cmd", "/c", "skin.bat"]
+	Dir      string   `json:"dir,omitempty"`             // absolute, or relative to the game folder
+	Timeout  int      `json:"timeout_seconds,omitempty"` // 0 = 60 seconds
+	Required bool     `json:"required,omitempty"`        // stop patching/launching if it fails
+}
+
+// HookConfig lists the hooks for each stage
+type HookConfig struct {
+	PrePatch  []Hook `json:"pre_patch,omitempty"`
+	PostPatch []Hook `json:"post_patch,omitempty"`
+	PreLaunch []Hook `json:"pre_launch,omitempty"`
+}
+
+// forStage returns the hooks for stage
+func (h *HookConfig) forStage(stage string) []Hook {
+	if h == nil {
+		return nil
+	}
+	switch stage {
+	case hookPrePatch:
+		return h.PrePatch
+	case hookPostPatch:
+		return h.PostPatch
+	case hookPreLaunch:
+		return h.PreLaunch
+	}
+	return nil
+}
+
+// hookError is a required hook that failed, with what it printed
+type hookError struct {
+	Stage  string
+	Name   string
+	Err    error
+	Output string
+}
+
+func (e *hookError) Error() string {
+	message := fmt.Sprintf("%s hook %q failed: %v", e.Stage, e.Name, e.Err)
+	if output := strings.TrimSpace(e.Output); output != "" {
+		if len(output) > hookOutputShown {
+			output = "..." + output[len(output)-hookOutputShown:]
+		}
+		message += "\n\n" + output
+	}
+	return message
+}
+
+// runHooks runs cfg's hooks for stage in order from gameFolder. A failing
+// required hook stops the rest and is returned as a *hookError; other
+// failures are only logged.
+func runHooks(stage string, cfg *Config, gameFolder string) error {
+	for i, hook := range cfg.Hooks.forStage(stage) {
+		name := hook.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if len(hook.Command) == 0 {
+			slog.Warn("hook has no command", "stage", stage, "hook", name)
+			continue
+		}
+
+		output, err := runHook(stage, name, hook, cfg.ServerURL, gameFolder)
+		if err == nil {
+			continue
+		}
+		if hook.Required {
+			return &hookError{Stage: stage, Name: name, Err: err, Output: output}
+		}
+		slog.Warn("optional hook failed, continuing", "stage", stage, "hook", name, "error", err)
+	}
+	return nil
+}
+
+// runHook runs one hook and returns its combined output
+func runHook(stage, name string, hook Hook, serverURL, gameFolder string) (string, error) {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.WaitDelay = 5 * time.Second // don't hang on output held open by child processes
+	cmd.Dir = gameFolder
+	if hook.Dir != "" {
+		cmd.Dir = resolveDir(hook.Dir, gameFolder)
+	}
+	cmd.Env = append(os.Environ(),
+		"EQ_HOOK_STAGE="+stage,
+		"EQ_GAME_DIR="+gameFolder,
+		"EQ_SERVER_URL="+serverURL,
+	)
+
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %d seconds", timeout)
+	}
+
+	logged := out
+	if len(logged) > hookOutputLogged {
+		logged = logged[len(logged)-hookOutputLogged:]
+	}
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	slog.Log(context.Background(), level, "hook finished", "stage", stage, "hook", name,
+		"command", hook.Command, "duration", time.Since(start), "error", err, "output", string(logged))
+
+	return string(out), err
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// manifest.json, news.json and the theme are kept in .patcher-cache with
+// their ETag and Last-Modified, so an unchanged manifest costs a 304 instead
+// of a full download, and the last copy is still there when the server is
+// down
+const httpCacheDir = ".patcher-cache"
+
+// cacheEntry describes one cached response; the body is stored next to it
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Checked      time.Time `json:"checked"` // last time the server confirmed this copy
+}
+
+func cachePaths(rawURL string) (meta, body string) {
+	hash := sha256.Sum256([]byte(rawURL))
+	name := hex.EncodeToString(hash[:8])
+	return filepath.Join(httpCacheDir, name+".json"), filepath.Join(httpCacheDir, name+".body")
+}
+
+// loadCacheEntry returns the cached response for rawURL, or nil if there
+// isn't a complete one
+func loadCacheEntry(rawURL string) (*cacheEntry, []byte) {
+	metaPath, bodyPath := cachePaths(rawURL)
+
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != rawURL {
+		return nil, nil
+	}
+	body, err := os.ReadFile(bodyPath)
+	if err != nil {
+		return nil, nil
+	}
+	return &entry, body
+}
+
+func (e *cacheEntry) save(body []byte) error {
+	metaPath, bodyPath := cachePaths(e.URL)
+	if err := os.MkdirAll(httpCacheDir, 0755); err != nil {
+		return err
+	}
+
+	// Body first, so the metadata never describes a body we don't have
+	if body != nil {
+		if err := writeFileAtomic(bodyPath, body); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(metaPath, data)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, path)
+}
+
+// cachedGet downloads rawURL, asking the server to skip the body if our
+// cached copy is still current. The cached copy is returned on a 304.
+func cachedGet(rawURL string) ([]byte, error) {
+	return cachedGetLimited(rawURL, 0, true, nil)
+}
+
+// cachedGetLimited is cachedGet for bodies of at most maxBytes (0 = no
+// limit), sending the configured credentials only if withAuth is set. A
+// larger body is an error and isn't cached, as is one check (if not nil)
+// rejects.
+func cachedGetLimited(rawURL string, maxBytes int64, withAuth bool, check func(body []byte) error) ([]byte, error) {
+	entry, cached := loadCacheEntry(rawURL)
+
+	header := http.Header{}
+	if entry != nil {
+		if entry.ETag != "" {
+			header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	get := httpGetWith
+	if !withAuth {
+		get = httpGetAnonymous
+	}
+	resp, err := get(rawURL, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && entry != nil:
+		entry.Checked = time.Now()
+		entry.save(nil)
+		return cached, nil
+
+	case resp.StatusCode == 200:
+		reader := io.Reader(resp.Body)
+		if maxBytes > 0 {
+			reader = io.LimitReader(resp.Body, maxBytes+1)
+		}
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		if maxBytes > 0 && int64(len(body)) > maxBytes {
+			return nil, fmt.Errorf("%s is larger than %d KB", rawURL, maxBytes/1024)
+		}
+		if check != nil {
+			if err := check(body); err != nil {
+				return nil, fmt.Errorf("%s: %v", rawURL, err)
+			}
+		}
+		entry = &cacheEntry{
+			URL:          rawURL,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Checked:      time.Now(),
+		}
+		entry.save(body)
+		return body, nil
+	}
+
+	return nil, &statusError{resp.StatusCode}
+}
+
+// statusError is an unexpected HTTP status from the server
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("server returned status %d", e.code)
+}
+
+// isNotFound reports whether err is a 404 from the server, e.g. for an
+// optional file the server doesn't publish
+func isNotFound(err error) bool {
+	var se *statusError
+	return errors.As(err, &se) && se.code == http.StatusNotFound
+}
+
+// cachedCopy returns the last copy of rawURL we downloaded and when the
+// server last confirmed it, for use while the server is unreachable
+func cachedCopy(rawURL string) ([]byte, time.Time, error) {
+	entry, body := loadCacheEntry(rawURL)
+	if entry == nil {
+		return nil, time.Time{}, fmt.Errorf("no cached copy of %s", rawURL)
+	}
+	return body, entry.Checked, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// httpClient is used for every request to the patch server: manifest, news,
+// files and token refreshes
+var httpClient = http.DefaultClient
+
+// configureHTTP sets up httpClient from the proxy, ca_bundle and spki_pin
+// settings. If they can't be applied, every request fails with the error
+// rather than quietly going out without the proxy or pin.
+func configureHTTP(proxy, caBundle, pin string) error {
+	transport, err := newTransport(proxy, caBundle, pin)
+	if err != nil {
+		err = fmt.Errorf("invalid network settings: %v", err)
+		httpClient = &http.Client{Transport: failingTransport{err}}
+		return err
+	}
+	httpClient = &http.Client{Transport: transport}
+	return nil
+}
+
+func newTransport(proxy, caBundle, pin string) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	// An explicit proxy wins over HTTP_PROXY/HTTPS_PROXY
+	if proxy != "" {
+		if !strings.Contains(proxy, "://") {
+			proxy = "http://" + proxy
+		}
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: %v", err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("proxy: unsupported scheme %q (use http, https or socks5)", proxyURL.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{}
+
+	// Trust our own CA (or self-signed certificate) as well as the system's
+	if caBundle != "" {
+		pem, err := os.ReadFile(caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("ca_bundle: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_bundle: no PEM certificates found in %s", caBundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if pin != "" {
+		pins, err := parsePins(pin)
+		if err != nil {
+			return nil, fmt.Errorf("spki_pin: %v", err)
+		}
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			return checkPins(cs.PeerCertificates, pins)
+		}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// parsePins reads a comma separated list of base64 SHA-256 hashes of the
+// server's public key (SubjectPublicKeyInfo), optionally prefixed with
+// "sha256/". Listing the next key as well allows it to be rotated.
+func parsePins(value string) (map[string]bool, error) {
+	pins := make(map[string]bool)
+	for _, pin := range strings.Split(value, ",") {
+		pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+		if pin == "" {
+			continue
+		}
+		hash, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("%q is not a base64 SHA-256 hash", pin)
+		}
+		pins[pin] = true
+	}
+	if len(pins) == 0 {
+		return nil, fmt.Errorf("no pins given")
+	}
+	return pins, nil
+}
+
+// checkPins passes if any certificate the server sent has a pinned public
+// key. The usual certificate checks have already been done by then.
+func checkPins(certs []*x509.Certificate, pins map[string]bool) error {
+	for _, cert := range certs {
+		hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		if pins[base64.StdEncoding.EncodeToString(hash[:])] {
+			return nil
+		}
+	}
+	return fmt.Errorf("server certificate does not match spki_pin")
+}
+
+// failingTransport refuses every request, used when the network settings
+// are broken
+type failingTransport struct {
+	err error
+}
+
+func (t failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
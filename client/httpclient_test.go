@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+)
+
+func TestParsePins(t *testing.T) {
+	sum := sha256.Sum256([]byte("key one"))
+	pin1 := base64.StdEncoding.EncodeToString(sum[:])
+	sum = sha256.Sum256([]byte("key two"))
+	pin2 := base64.StdEncoding.EncodeToString(sum[:])
+
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: pin1, want: []string{pin1}},
+		{value: "sha256/" + pin1, want: []string{pin1}},
+		{value: pin1 + ", sha256/" + pin2 + ",", want: []string{pin1, pin2}},
+		{value: "", wantErr: true},
+		{value: " , ", wantErr: true},
+		{value: "not base64!", wantErr: true},
+		{value: base64.StdEncoding.EncodeToString([]byte("too short")), wantErr: true},
+		{value: pin1 + ",garbage", wantErr: true},
+	}
+	for _, tt := range tests {
+		pins, err := parsePins(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePins(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if len(pins) != len(tt.want) {
+			t.Errorf("parsePins(%q) = %v, want %v", tt.value, pins, tt.want)
+		}
+		for _, pin := range tt.want {
+			if !pins[pin] {
+				t.Errorf("parsePins(%q) is missing %s", tt.value, pin)
+			}
+		}
+	}
+}
+
+func TestCheckPins(t *testing.T) {
+	cert := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("server key")}
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(sum[:])
+	other := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("intermediate key")}
+
+	tests := []struct {
+		name  string
+		certs []*x509.Certificate
+		pins  map[string]bool
+		ok    bool
+	}{
+		{"leaf pinned", []*x509.Certificate{cert, other}, map[string]bool{pin: true}, true},
+		{"intermediate pinned", []*x509.Certificate{other, cert}, map[string]bool{pin: true}, true},
+		{"no match", []*x509.Certificate{other}, map[string]bool{pin: true}, false},
+		{"no certificates", nil, map[string]bool{pin: true}, false},
+	}
+	for _, tt := range tests {
+		if err := checkPins(tt.certs, tt.pins); (err == nil) != tt.ok {
+			t.Errorf("%s: checkPins() = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
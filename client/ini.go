@@ -76,6 +76,10 @@ func (ini *INIFile) Set(section, key, value string) {
 	ini.sections[section][key] = value
 }
 
+func (ini *INIFile) Delete(section, key string) {
+	delete(ini.sections[section], key)
+}
+
 func (ini *INIFile) Save() error {
 	file, err := os.Create(ini.path)
 	if err != nil {
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"log/slog"
+	"sync/atomic"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Servers can rebrand LaunchPad without rebuilding it by publishing a
+// theme.json next to manifest.json. It is cached like the news, so the last
+// theme still shows while the server is down. Anything the theme leaves out,
+// or that can't be loaded, falls back to the built-in look.
+
+const themeFile = "theme.json"
+
+// LauncherTheme is theme.json on the patch server. Every field is optional.
+type LauncherTheme struct {
+	Title      string            `json:"title,omitempty"`      // replaces "EverQuest" above the server name
+	Background string            `json:"background,omitempty"` // JPEG or PNG, absolute or relative to server_url
+	Logo       string            `json:"logo,omitempty"`       // shown instead of the title text
+	Colors     ThemeColors       `json:"colors"`
+	Buttons    map[string]string `json:"buttons,omitempty"` // play, exit, graphics, verify, tools, website
+}
+
+// ThemeColors are "#RRGGBB" colors
+type ThemeColors struct {
+	Accent     string `json:"accent,omitempty"` // PLAY button, progress bar, focus
+	Button     string `json:"button,omitempty"` // other buttons
+	Title      string `json:"title,omitempty"`
+	ServerName string `json:"server_name,omitempty"`
+}
+
+// Size limits for theme downloads, so a broken or hostile theme can't use
+// up the player's memory
+const (
+	maxThemeBytes      = 64 * 1024       // theme.json
+	maxThemeImageBytes = 4 * 1024 * 1024 // each image
+	maxThemeImageSide  = 4096            // width and height of each image, in pixels
+)
+
+const (
+	defaultTitle = "EverQuest"
+	logoWidth    = 320
+	logoHeight   = 80
+)
+
+// Widgets the theme changes, created in main
+var (
+	titleLabel     *canvas.Text
+	logoImage      *canvas.Image
+	backgroundImg  *canvas.Image
+	graphicsButton *widget.Button
+)
+
+// themeGeneration is bumped whenever the theme is reloaded, so a slow
+// download for the previous server is thrown away
+var themeGeneration int64
+
+// accentTheme is the default fyne theme with the server's accent colors
+type accentTheme struct {
+	fyne.Theme
+	primary color.Color // nil keeps the default
+	button  color.Color
+}
+
+func (t *accentTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
+	switch {
+	case name == theme.ColorNamePrimary && t.primary != nil:
+		return t.primary
+	case name == theme.ColorNameButton && t.button != nil:
+		return t.button
+	}
+	return t.Theme.Color(name, variant)
+}
+
+// loadTheme shows the cached theme for serverURL straight away, then checks
+// the server for a newer one
+func loadTheme(serverURL string) {
+	generation := atomic.AddInt64(&themeGeneration, 1)
+	themeURL := serverURLFor(serverURL, themeFile)
+
+	cached := func(url string) ([]byte, error) {
+		data, _, err := cachedCopy(url)
+		return data, err
+	}
+	data, err := cached(themeURL)
+	if err == nil {
+		applyTheme(serverURL, parseTheme(data), cached)
+	} else {
+		applyTheme(serverURL, nil, nil)
+	}
+
+	go func() {
+		data, err := cachedGetLimited(themeURL, maxThemeBytes, true, nil)
+		if err != nil && !isNotFound(err) {
+			slog.Debug("theme not updated", "url", themeURL, "error", err)
+			return // Keep the cached theme
+		}
+
+		var t *LauncherTheme
+		if err == nil {
+			t = parseTheme(data)
+		}
+		fetch := func(url string) ([]byte, error) {
+			// Images may be on a CDN; only the patch server gets credentials
+			data, err := cachedGetLimited(url, maxThemeImageBytes, sameHost(url, serverURL), checkThemeImage)
+			if err != nil {
+				return cached(url)
+			}
+			return data, nil
+		}
+		if atomic.LoadInt64(&themeGeneration) != generation {
+			return // Switched server in the meantime
+		}
+		applyTheme(serverURL, t, fetch)
+	}()
+}
+
+// parseTheme reads theme.json, returning nil if it is invalid
+func parseTheme(data []byte) *LauncherTheme {
+	if len(data) > maxThemeBytes {
+		slog.Warn("theme.json too large, using the built-in look", "bytes", len(data))
+		return nil
+	}
+	var t LauncherTheme
+	if err := json.Unmarshal(data, &t); err != nil {
+		slog.Warn("invalid theme.json, using the built-in look", "error", err)
+		return nil
+	}
+	return &t
+}
+
+// applyTheme puts t on the window, using get to load its images. A nil t
+// restores the built-in look.
+func applyTheme(serverURL string, t *LauncherTheme, get func(url string) ([]byte, error)) {
+	if t == nil {
+		t = &LauncherTheme{}
+	}
+
+	background := themeImage(serverURL, t.Background, get)
+	if background == nil {
+		background = fyne.NewStaticResource("background", backgroundImage)
+	}
+	backgroundImg.Resource = background
+	backgroundImg.Refresh()
+
+	if logo := themeImage(serverURL, t.Logo, get); logo != nil {
+		logoImage.Resource = logo
+		logoImage.Show()
+		titleLabel.Hide()
+	} else {
+		logoImage.Hide()
+		titleLabel.Show()
+	}
+	logoImage.Refresh()
+
+	titleLabel.Text = defaultTitle
+	if t.Title != "" {
+		titleLabel.Text = t.Title
+	}
+	titleLabel.Color = themeColor(t.Colors.Title)
+	titleLabel.Refresh()
+	serverLabel.Color = themeColor(t.Colors.ServerName)
+	serverLabel.Refresh()
+
+	playButton.SetText(buttonLabel(t, "play", "PLAY"))
+	exitButton.SetText(buttonLabel(t, "exit", "Exit"))
+	graphicsButton.SetText(buttonLabel(t, "graphics", "Graphics Settings"))
+	verifyButton.SetText(buttonLabel(t, "verify", "Verify & Repair"))
+	toolsButton.SetText(buttonLabel(t, "tools", "Tools"))
+	if config.WebsiteLabel == "" { // the config's own label wins
+		websiteButton.SetText(buttonLabel(t, "website", "Visit Website"))
+	}
+
+	accent := &accentTheme{Theme: theme.DefaultTheme()}
+	if t.Colors.Accent != "" {
+		accent.primary = parseHexColor(t.Colors.Accent)
+	}
+	if t.Colors.Button != "" {
+		accent.button = parseHexColor(t.Colors.Button)
+	}
+	fyne.CurrentApp().Settings().SetTheme(accent)
+}
+
+// themeImage loads an image from the theme, returning nil if ref is empty or
+// the image can't be loaded
+func themeImage(serverURL, ref string, get func(url string) ([]byte, error)) fyne.Resource {
+	if ref == "" || get == nil {
+		return nil
+	}
+	url := serverURLFor(serverURL, ref)
+	data, err := get(url)
+	if err == nil {
+		err = checkThemeImage(data)
+	}
+	if err != nil {
+		slog.Warn("could not load theme image", "url", url, "error", err)
+		return nil
+	}
+	return fyne.NewStaticResource(url, data)
+}
+
+// checkThemeImage rejects images too large to load safely. Only the header
+// is read: a small file can still claim a size that would take gigabytes to
+// decode.
+func checkThemeImage(data []byte) error {
+	if len(data) > maxThemeImageBytes {
+		return fmt.Errorf("larger than %d MB", maxThemeImageBytes/1024/1024)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if cfg.Width > maxThemeImageSide || cfg.Height > maxThemeImageSide {
+		return fmt.Errorf("%dx%d pixels, larger than %dx%d", cfg.Width, cfg.Height, maxThemeImageSide, maxThemeImageSide)
+	}
+	return nil
+}
+
+// themeColor parses a theme color, or returns the default text color
+func themeColor(hex string) color.Color {
+	if hex == "" {
+		return theme.ForegroundColor()
+	}
+	return parseHexColor(hex)
+}
+
+// buttonLabel is the theme's label for a button, or fallback
+func buttonLabel(t *LauncherTheme, button, fallback string) string {
+	if label := t.Buttons[button]; label != "" {
+		return label
+	}
+	return fallback
+}
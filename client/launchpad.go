@@ -3,16 +3,17 @@ package main
 import (
 	"crypto/md5"
 	"encoding/json"
+	"errors"
 	_ "embed"
 	"fmt"
 	"image/color"
 	"io"
-	"net/http"
+	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -32,21 +33,16 @@ type FileEntry struct {
 	Path string `json:"path"`
 	MD5  string `json:"md5"`
 	Size int64  `json:"size"`
+	Pack string `json:"pack,omitempty"` // content pack the file belongs to, see packs.go
 }
 
 type Manifest struct {
-	Version string      `json:"version"`
-	Files   []FileEntry `json:"files"`
-}
+	Version  string        `json:"version"`
+	Files    []FileEntry   `json:"files"`
+	Launcher []FileEntry   `json:"launcher,omitempty"` // launcher binaries, see selfupdate.go
+	Packs    []ContentPack `json:"packs,omitempty"`    // optional content the player can turn on
 
-type Config struct {
-	ServerURL     string `json:"server_url"`
-	ServerName    string `json:"server_name"`
-	LauncherTitle string `json:"launcher_title"`
-	WebsiteURL    string `json:"website_url"`
-	WebsiteLabel  string `json:"website_label"`
-	GameExe       string `json:"game_exe"`
-	GameArgs      string `json:"game_args"`
+	Installed map[string]installedFile `json:"installed,omitempty"` // local manifest only, see saveLocalManifest
 }
 
 type NewsItem struct {
@@ -65,8 +61,7 @@ type NewsConfig struct {
 }
 
 const (
-	configFile        = "patcher-config.json"
-	localManifestFile = ".patcher-manifest.json"
+	configFile = "patcher-config.json"
 )
 
 // Directories managed by the patcher (these mirror the EQ client structure)
@@ -88,13 +83,32 @@ var managedDirs = []string{
 }
 
 var (
-	config      *Config
-	statusLabel *widget.Label
-	progressBar *widget.ProgressBar
-	playButton  *widget.Button
-	exitButton  *widget.Button
+	config       *Config // active settings: the config file with the selected profile applied
+	rootConfig   *Config // the config file as stored
+	statusLabel  *widget.Label
+	progressBar  *widget.ProgressBar
+	playButton   *widget.Button
+	exitButton   *widget.Button
+	verifyButton *widget.Button
+	toolsButton  *widget.Button
+
+	profileSelect *widget.Select // nil unless there are several profiles
+	launchSelect  *widget.Select // nil unless there are several launch profiles
+	serverLabel   *canvas.Text
+	websiteButton *widget.Button
+	websiteSpacer fyne.CanvasObject
+
+	launcherDir string // where LaunchPad.exe and patcher-config.json live
+	gameFolder  string // game folder of the active profile (the working directory)
 )
 
+// Set if proxy, ca_bundle or spki_pin couldn't be applied; shown once the
+// window is up
+var httpConfigErr error
+
+// Set if the selected profile's game folder can't be used
+var profileErr error
+
 func main() {
 	myApp := app.New()
 
@@ -102,39 +116,84 @@ func main() {
 	// This ensures all file operations happen in the correct location
 	exePath, err := os.Executable()
 	if err == nil {
-		launcherDir := filepath.Dir(exePath)
-		os.Chdir(launcherDir)
+		os.Chdir(filepath.Dir(exePath))
+	}
+	launcherDir, _ = os.Getwd()
+	gameFolder = launcherDir
+
+	// Finish or clean up after a launcher self-update
+	healthMarker := os.Getenv(selfUpdateHealthEnv)
+	os.Unsetenv(selfUpdateHealthEnv)
+	if healthMarker == "" {
+		cleanupSelfUpdate()
 	}
 
 	// Load configuration first to get launcher title
-	config, err = loadConfig()
+	rootConfig, err = loadConfig()
 	if err != nil {
-		config = createDefaultConfig()
+		rootConfig = createDefaultConfig()
+	}
+	downloadLimiter.SetKbps(rootConfig.MaxDownloadKbps)
+
+	// Log next to LaunchPad, not in the game folder
+	if err := setupLogging(filepath.Join(launcherDir, logDirName), "launchpad.log", rootConfig.LogLevel); err != nil {
+		slog.Warn("logging setup", "error", err)
+	}
+	slog.Info("LaunchPad starting", "os", runtime.GOOS, "arch", runtime.GOARCH, "dir", launcherDir)
+
+	// Start with the last selected server profile (patches happen in its
+	// game folder), or the first one if it has since been removed
+	if findProfile(rootConfig.Profiles, rootConfig.ActiveProfile) == nil {
+		rootConfig.ActiveProfile = ""
+	}
+	if findLaunchProfile(rootConfig.LaunchProfiles, rootConfig.ActiveLaunchProfile) == nil {
+		rootConfig.ActiveLaunchProfile = ""
 	}
+	profileErr = activateProfile(rootConfig.ActiveProfile)
+	if profileErr != nil {
+		config = applyProfile(rootConfig, findProfile(rootConfig.Profiles, rootConfig.ActiveProfile))
+	}
+
+	// Proxy, CA bundle and pin apply to every request, including the news
+	httpConfigErr = configureHTTP(config.Proxy, config.CABundle, config.SPKIPin)
 
-	// Use configurable title
-	windowTitle := config.LauncherTitle
-	if windowTitle == "" {
-		windowTitle = "EverQuest LaunchPad"
+	// Don't leave credentials typed into the config file in plain text
+	saveCredentials = saveActiveConfig
+	sealedRoot := sealCredentials(rootConfig.Auth)
+	sealedProfiles := sealProfileCredentials(rootConfig.Profiles)
+	if sealedRoot || sealedProfiles {
+		saveConfig(rootConfig)
 	}
-	myWindow := myApp.NewWindow(windowTitle)
 
-	// Load background image
-	bg := canvas.NewImageFromReader(strings.NewReader(string(backgroundImage)), "background")
-	bg.FillMode = canvas.ImageFillStretch
+	// Title is set from the config by applyBranding
+	myWindow := myApp.NewWindow("EverQuest LaunchPad")
+
+	// Load background image (replaced by the server's theme.json, if any)
+	backgroundImg = canvas.NewImageFromResource(fyne.NewStaticResource("background", backgroundImage))
+	backgroundImg.FillMode = canvas.ImageFillStretch
 
 	// Create UI elements with EQ-style colors
-	titleLabel := canvas.NewText("EverQuest", theme.ForegroundColor())
+	titleLabel = canvas.NewText(defaultTitle, theme.ForegroundColor())
 	titleLabel.TextSize = 28
 	titleLabel.TextStyle = fyne.TextStyle{Bold: true}
 	titleLabel.Alignment = fyne.TextAlignCenter
 
-	serverLabel := canvas.NewText(config.ServerName, theme.ForegroundColor())
+	// Server logo, shown instead of the title when the theme has one
+	logoImage = canvas.NewImageFromResource(nil)
+	logoImage.FillMode = canvas.ImageFillContain
+	logoImage.SetMinSize(fyne.NewSize(logoWidth, logoHeight))
+	logoImage.Hide()
+
+	serverLabel = canvas.NewText(config.ServerName, theme.ForegroundColor())
 	serverLabel.TextSize = 16
 	serverLabel.Alignment = fyne.TextAlignCenter
 
+	// Login/world server status (under server name)
+	statusLine := createServerStatusLabel()
+
 	// Create news fader with frame (centered, below server name)
-	newsFader := createNewsFader(config.ServerURL)
+	newsFader := createNewsFader()
+	loadNews(config.ServerURL)
 
 	// Create semi-transparent background for news
 	newsBackground := canvas.NewRectangle(color.RGBA{R: 0, G: 0, B: 0, A: 180})
@@ -148,6 +207,8 @@ func main() {
 	progressBar = widget.NewProgressBar()
 	progressBar.Hide()
 
+	speedSelect := createSpeedLimitSelect()
+
 	playButton = widget.NewButton("PLAY", func() {
 		go launchGameOnly(myWindow)
 	})
@@ -159,21 +220,29 @@ func main() {
 	})
 
 	// Graphics Settings button (at top)
-	graphicsButton := widget.NewButton("Graphics Settings", func() {
+	graphicsButton = widget.NewButton("Graphics Settings", func() {
 		showGraphicsDialog(myWindow)
 	})
 
-	// Website button (if configured)
-	var websiteButton *widget.Button
-	if config.WebsiteURL != "" {
-		buttonLabel := config.WebsiteLabel
-		if buttonLabel == "" {
-			buttonLabel = "Visit Website"
-		}
-		websiteButton = widget.NewButton(buttonLabel, func() {
-			openBrowser(config.WebsiteURL)
-		})
+	// Verify & Repair button (at top) - rehashes every file, ignoring the
+	// cache, and shows a report of what's wrong before fixing it
+	verifyButton = widget.NewButton("Verify & Repair", func() {
+		go startRepair(myWindow)
+	})
+	verifyButton.Disable() // Disabled until update check completes
+
+	// Tools menu (at top) - backup restore and uninstall
+	toolsButton = widget.NewButton("Tools", nil)
+	toolsButton.OnTapped = func() {
+		showToolsMenu(myWindow)
 	}
+	toolsButton.Disable() // Disabled until update check completes
+
+	// Website button (hidden by applyBranding if not configured)
+	websiteButton = widget.NewButton("Visit Website", func() {
+		openBrowser(config.WebsiteURL)
+	})
+	websiteSpacer = layout.NewSpacer()
 
 	// Create button layout per user specs:
 	// - Graphics Settings at top (centered)
@@ -181,33 +250,47 @@ func main() {
 	// - Website button (if configured) on left before Exit
 	// - Exit button at bottom left
 	// Add spacing between buttons for better visual layout
+	// Launch profile selector next to PLAY when there is a choice
+	var playRow fyne.CanvasObject = playButton
+	if len(rootConfig.LaunchProfiles) > 1 {
+		launchSelect = createLaunchSelect()
+		playRow = container.NewHBox(playButton, launchSelect)
+	}
+
 	leftButtons := container.NewVBox(
-		playButton,
+		playRow,
 		layout.NewSpacer(),
+		websiteButton,
+		websiteSpacer,
+		exitButton,
 	)
-	if websiteButton != nil {
-		leftButtons.Add(websiteButton)
-		leftButtons.Add(layout.NewSpacer())
+
+	// Server selector (at top) when the config lists several profiles
+	topButtons := container.NewHBox(graphicsButton, verifyButton, toolsButton)
+	if len(rootConfig.Profiles) > 1 {
+		profileSelect = createProfileSelect(myWindow)
+		topButtons = container.NewHBox(profileSelect, graphicsButton, verifyButton, toolsButton)
 	}
-	leftButtons.Add(exitButton)
 
 	// Create centered content with better spacing
 	centerContent := container.NewVBox(
 		layout.NewSpacer(),
-		container.NewCenter(titleLabel),
+		container.NewCenter(container.NewStack(titleLabel, logoImage)),
 		container.NewCenter(serverLabel),
+		container.NewCenter(statusLine),
 		container.NewCenter(newsFrame),
 		layout.NewSpacer(),
 		layout.NewSpacer(),
 		container.NewCenter(statusLabel),
 		container.NewCenter(progressBar),
+		container.NewCenter(container.NewHBox(widget.NewLabel("Download limit:"), speedSelect)),
 		layout.NewSpacer(),
 	)
 
 	// Create overlay container with new layout
 	overlay := container.NewBorder(
-		// Top: Graphics Settings button
-		container.NewCenter(graphicsButton),
+		// Top: server selector, Graphics Settings, Verify & Repair and Tools buttons
+		container.NewCenter(topButtons),
 		// Bottom: empty
 		nil,
 		// Left: Play, Website (optional), Exit buttons
@@ -223,13 +306,23 @@ func main() {
 	)
 
 	// Stack background and overlay
-	content := container.NewStack(bg, overlay)
+	content := container.NewStack(backgroundImg, overlay)
 
 	myWindow.SetContent(content)
+	applyBranding(myWindow)
+	loadTheme(config.ServerURL)
+	startStatusChecks()
 	myWindow.Resize(fyne.NewSize(600, 400))
 	myWindow.SetFixedSize(true)
 	myWindow.CenterOnScreen()
 
+	// Tell the previous launcher we started fine after a self-update
+	if healthMarker != "" {
+		myApp.Lifecycle().SetOnStarted(func() {
+			reportSelfUpdateHealthy(healthMarker)
+		})
+	}
+
 	// Check for updates on startup
 	go checkForUpdatesOnStartup(myWindow)
 
@@ -237,61 +330,148 @@ func main() {
 }
 
 func checkForUpdatesOnStartup(win fyne.Window) {
+	if httpConfigErr != nil {
+		showError(win, fmt.Sprintf("%v\n\nCheck proxy, ca_bundle and spki_pin in %s.", httpConfigErr, configFile))
+	}
+
+	// The selected profile's game folder is missing - let the player pick
+	// another one
+	if profileErr != nil {
+		statusLabel.SetText("⚠️ Game folder not found")
+		message := fmt.Sprintf("%v\n\nChoose where the game is installed now? You can also fix game_dir for this server in %s, or choose another server.", profileErr, configFile)
+		dialog.ShowConfirm("Game Folder Not Found", message, func(choose bool) {
+			if choose {
+				chooseGameFolder(win, false, func() { startUpdateCheck(win) })
+			}
+		}, win)
+		return
+	}
+
+	startUpdateCheck(win)
+}
+
+// recoverInterruptedUpdate finishes or rolls back an update of the current
+// game folder that was interrupted last time
+func recoverInterruptedUpdate(win fyne.Window) {
+	if _, err := recoverUpdate(); err != nil {
+		showError(win, fmt.Sprintf("A previous update was interrupted and could not be finished:\n\n%v\n\nClose the game if it is running, then use Verify & Repair to fix the game files.", err))
+	}
+}
+
+// checkForUpdates compares local files against the server manifest and
+// offers to apply any changes. Files whose size and modification time are
+// unchanged since they were last verified are not rehashed unless fullVerify
+// is set.
+func checkForUpdates(win fyne.Window, fullVerify bool) {
+	playButton.Disable()
+	setBusy(true)
+	// Cleared on every return except while the launcher update question is
+	// open; its answer hands over to code that clears it
+	busy := true
+	defer func() {
+		if busy {
+			setBusy(false)
+		}
+	}()
+
 	statusLabel.SetText("Checking for updates...")
 	progressBar.Show()
 	progressBar.SetValue(0)
 
 	// Download manifest
+	start := time.Now()
 	manifest, err := downloadManifest(config.ServerURL)
+	if err != nil {
+		slog.Warn("update check failed", "server", config.ServerURL, "error", err)
+	} else {
+		slog.Info("manifest", "server", config.ServerURL, "version", manifest.Version, "files", len(manifest.Files), "duration", time.Since(start))
+	}
+	if errors.Is(err, errAuthRequired) {
+		promptSignIn(win, func() { checkForUpdates(win, fullVerify) }, func() {
+			statusLabel.SetText("⚠️ Not signed in - Ready to play")
+			playButton.Enable()
+		})
+		return
+	}
 	if err != nil {
 		// Can't connect - allow playing anyway
-		statusLabel.SetText("⚠️ Update check failed - Ready to play")
+		statusLabel.SetText(offlineStatus())
 		progressBar.Hide()
 		playButton.Enable()
 		return
 	}
 
+	// Keep the saved server-config.json current; the box limit reads it
+	if _, err := downloadServerConfig(config.ServerURL); err != nil {
+		slog.Debug("server config not updated", "error", err)
+	}
+
 	// Check if launcher itself needs updating
-	launcherNeedsUpdate := checkLauncherUpdates(manifest)
-	if launcherNeedsUpdate {
+	outdated := checkLauncherUpdates(manifest)
+	if len(outdated) > 0 {
 		progressBar.Hide()
 		statusLabel.SetText("📦 Launcher update available")
 
-		dialog.ShowInformation(
+		busy = false
+		dialog.ShowConfirm(
 			"Launcher Update Available",
-			fmt.Sprintf("A new version of the launcher is available!\n\nPlease download the latest version:\n%s/eq-patcher-client.zip\n\nExtract and replace your current files.", config.ServerURL),
+			"A new version of the launcher is available!\n\nWould you like to update now?\n\n(LaunchPad will restart when the update is installed)",
+			func(update bool) {
+				if update {
+					go performSelfUpdate(win, manifest, outdated, fullVerify)
+				} else {
+					// Continue checking game files anyway
+					go checkGameFiles(win, manifest, fullVerify)
+				}
+			},
 			win,
 		)
-		// Continue checking game files anyway
+		return
 	}
 
-	statusLabel.SetText("Checking files...")
-	progressBar.SetValue(0.3)
+	checkGameFiles(win, manifest, fullVerify)
+}
 
-	// Check which files need updating
-	toDownload := []FileEntry{}
-	for _, file := range manifest.Files {
-		localPath := file.Path
+// offlineStatus describes the game files against the last manifest we
+// downloaded, for when the patch server can't be reached
+func offlineStatus() string {
+	manifest, checked, err := lastKnownManifest(config.ServerURL)
+	if err != nil {
+		return "⚠️ Update check failed - Ready to play"
+	}
 
-		// Check if file exists
-		info, err := os.Stat(localPath)
-		if os.IsNotExist(err) {
-			toDownload = append(toDownload, file)
-			continue
+	problems := 0
+	for _, result := range verifyFiles(manifest, false, nil) {
+		if result.Status != statusOK {
+			problems++
 		}
+	}
 
-		// Check size and hash
-		if info.Size() != file.Size {
-			toDownload = append(toDownload, file)
-			continue
-		}
+	if problems > 0 {
+		return fmt.Sprintf("⚠️ Offline - %d file(s) differ from version %s - Ready to play", problems, manifest.Version)
+	}
+	return fmt.Sprintf("⚠️ Offline - up to date with version %s as of %s - Ready to play", manifest.Version, checked.Format("Jan 2 15:04"))
+}
 
-		localMD5, err := calculateMD5(localPath)
-		if err != nil || localMD5 != file.MD5 {
-			toDownload = append(toDownload, file)
-			continue
-		}
+// checkGameFiles is the second half of checkForUpdates, run once any
+// launcher update has been dealt with
+func checkGameFiles(win fyne.Window, manifest *Manifest, fullVerify bool) {
+	playButton.Disable()
+	setBusy(true)
+	defer setBusy(false)
+
+	progressBar.Show()
+	if fullVerify {
+		statusLabel.SetText("Verifying all files...")
+	} else {
+		statusLabel.SetText("Checking files...")
 	}
+	progressBar.SetValue(0.3)
+
+	// Check which files need updating
+	toDownload := findFilesToUpdate(manifest, fullVerify, func(done, total int) {
+		progressBar.SetValue(0.3 + 0.7*float64(done)/float64(total))
+	})
 
 	// Check for obsolete files (files not in manifest)
 	toDelete := findObsoleteFiles(manifest)
@@ -299,6 +479,7 @@ func checkForUpdatesOnStartup(win fyne.Window) {
 	progressBar.Hide()
 
 	totalChanges := len(toDownload) + len(toDelete)
+	slog.Info("files checked", "version", manifest.Version, "full", fullVerify, "download", len(toDownload), "delete", len(toDelete))
 
 	if totalChanges > 0 {
 		// Updates available - ask user
@@ -322,6 +503,7 @@ func checkForUpdatesOnStartup(win fyne.Window) {
 					go performUpdate(win, manifest, toDownload, toDelete)
 				} else {
 					// Skip updates
+					slog.Info("update skipped by player", "version", manifest.Version)
 					statusLabel.SetText("✓ Ready to play (updates skipped)")
 					playButton.Enable()
 				}
@@ -330,7 +512,7 @@ func checkForUpdatesOnStartup(win fyne.Window) {
 		)
 	} else {
 		// No updates needed - save current manifest as our local record
-		saveLocalManifest(manifest)
+		saveLocalManifest(manifest, manifestPaths(manifest))
 		statusLabel.SetText("✓ Up to date - Ready to play")
 		playButton.Enable()
 	}
@@ -338,20 +520,60 @@ func checkForUpdatesOnStartup(win fyne.Window) {
 
 func performUpdate(win fyne.Window, manifest *Manifest, toDownload []FileEntry, toDelete []string) {
 	playButton.Disable()
+	setBusy(true)
+	defer setBusy(false)
 	progressBar.Show()
 	progressBar.SetValue(0)
 
 	totalOperations := len(toDownload) + len(toDelete)
 	currentOp := 0
+	start := time.Now()
+	slog.Info("update started", "version", manifest.Version, "download", len(toDownload), "delete", len(toDelete))
 
-	// Download new/updated files
+	// Make sure the update fits before downloading anything
+	if err := checkDiskSpace(toDownload); err != nil {
+		statusLabel.SetText("⚠️ Not enough disk space")
+		progressBar.Hide()
+		showError(win, err.Error())
+		playButton.Enable()
+		return
+	}
+
+	// Originals that don't fit in the backup store would be lost for good
+	if unbacked := unbackedOriginals(toDownload); len(unbacked) > 0 && !confirmUnbackedOverwrite(win, unbacked) {
+		statusLabel.SetText("Update cancelled - Ready to play")
+		progressBar.Hide()
+		playButton.Enable()
+		return
+	}
+
+	if err := runHooks(hookPrePatch, config, gameFolder); err != nil {
+		statusLabel.SetText("⚠️ Update cancelled by pre-patch hook")
+		progressBar.Hide()
+		showError(win, err.Error())
+		playButton.Enable()
+		return
+	}
+
+	txn, err := beginUpdate(manifest)
+	if err != nil {
+		statusLabel.SetText("⚠️ Update failed")
+		progressBar.Hide()
+		showError(win, err.Error())
+		playButton.Enable()
+		return
+	}
+
+	// Download new/updated files into the staging folder
 	for _, file := range toDownload {
 		progress := float64(currentOp) / float64(totalOperations)
 		progressBar.SetValue(progress)
 		statusLabel.SetText(fmt.Sprintf("📥 Downloading %s (%d/%d)", filepath.Base(file.Path), currentOp+1, totalOperations))
 
-		err := downloadFile(config.ServerURL, file.Path)
+		err := txn.stage(file)
 		if err != nil {
+			slog.Error("download failed", "path", file.Path, "error", err)
+			txn.abort()
 			statusLabel.SetText("⚠️ Download failed")
 			progressBar.Hide()
 			showError(win, fmt.Sprintf("Failed to download %s: %v", file.Path, err))
@@ -361,34 +583,212 @@ func performUpdate(win fyne.Window, manifest *Manifest, toDownload []FileEntry,
 		currentOp++
 	}
 
-	// Delete obsolete files
-	for _, filePath := range toDelete {
-		progress := float64(currentOp) / float64(totalOperations)
-		progressBar.SetValue(progress)
-		statusLabel.SetText(fmt.Sprintf("🗑️ Removing %s (%d/%d)", filepath.Base(filePath), currentOp+1, totalOperations))
-
-		err := os.Remove(filePath)
-		if err != nil {
-			// Don't fail the entire update if we can't delete a file
-			// Just log it and continue
-			fmt.Printf("Warning: Could not delete %s: %v\n", filePath, err)
+	// Swap everything into place and remove obsolete files in one go
+	statusLabel.SetText(fmt.Sprintf("Installing %d file(s)...", len(toDownload)))
+	err = txn.commit(toDelete)
+	if err != nil {
+		slog.Error("update failed", "version", manifest.Version, "error", err)
+		statusLabel.SetText("⚠️ Update failed")
+		progressBar.Hide()
+		if errors.Is(err, errRollbackFailed) {
+			showError(win, fmt.Sprintf("The update could not be installed: %v\n\nSome files could not be put back yet. Close the game if it is running, then restart LaunchPad to finish undoing the update.", err))
+		} else {
+			showError(win, fmt.Sprintf("The update could not be installed: %v\n\nNo files were changed. If the game is running, close it and try again.", err))
 		}
-		currentOp++
+		playButton.Enable()
+		return
 	}
 
-	// Save the server manifest as our local record
-	saveLocalManifest(manifest)
-
+	slog.Info("update installed", "version", manifest.Version, "duration", time.Since(start))
 	progressBar.SetValue(1.0)
+
+	if err := runHooks(hookPostPatch, config, gameFolder); err != nil {
+		statusLabel.SetText("⚠️ Updated, but a post-patch hook failed")
+		progressBar.Hide()
+		showError(win, err.Error())
+		playButton.Enable()
+		return
+	}
+
 	statusLabel.SetText("✓ All files updated - Ready to play")
 	progressBar.Hide()
 	playButton.Enable()
 }
 
+// setBusy disables the buttons that start file operations while one is running
+func setBusy(busy bool) {
+	if busy {
+		verifyButton.Disable()
+		toolsButton.Disable()
+		if profileSelect != nil {
+			profileSelect.Disable()
+		}
+	} else {
+		verifyButton.Enable()
+		toolsButton.Enable()
+		if profileSelect != nil {
+			profileSelect.Enable()
+		}
+	}
+}
+
+// showToolsMenu pops up the Tools menu under its button
+func showToolsMenu(win fyne.Window) {
+	// Ticked when LaunchPad watches the game for crashes
+	stayResidentItem := fyne.NewMenuItem("Stay Open While Playing", func() {
+		setStayResident(!config.StayResident)
+	})
+	stayResidentItem.Checked = config.StayResident
+
+	menu := fyne.NewMenu("",
+		fyne.NewMenuItem("Restore Original Files...", func() {
+			confirmRestoreOriginals(win)
+		}),
+		fyne.NewMenuItem("Uninstall Patch...", func() {
+			confirmUninstallPatch(win)
+		}),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Change Game Folder...", func() {
+			chooseGameFolder(win, false, func() { startUpdateCheck(win) })
+		}),
+		fyne.NewMenuItem("Content Packs...", func() {
+			go showPacksDialog(win)
+		}),
+		fyne.NewMenuItem("Multi-Box Launch...", func() {
+			go showMultiboxDialog(win)
+		}),
+		stayResidentItem,
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Create Support Bundle...", func() {
+			createSupportBundle(win)
+		}),
+	)
+
+	pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(toolsButton)
+	pos = pos.Add(fyne.NewPos(0, toolsButton.Size().Height))
+	widget.ShowPopUpMenuAtPosition(menu, win.Canvas(), pos)
+}
+
+func confirmRestoreOriginals(win fyne.Window) {
+	backups := loadBackupIndex()
+	if len(backups.Files) == 0 {
+		dialog.ShowInformation("Restore Original Files", "There are no backed up original files.", win)
+		return
+	}
+
+	dialog.ShowConfirm(
+		"Restore Original Files",
+		fmt.Sprintf("Restore %d original file(s) (%.1f MB) that were replaced by patches?\n\nThe backups are kept, and the next update check will offer to re-apply the patch.", len(backups.Files), float64(backups.totalSize())/1024/1024),
+		func(restore bool) {
+			if !restore {
+				return
+			}
+			go func() {
+				setBusy(true)
+				defer setBusy(false)
+				statusLabel.SetText("Restoring original files...")
+
+				restored, err := restoreOriginals()
+				if err != nil {
+					statusLabel.SetText("⚠️ Restore failed")
+					showError(win, fmt.Sprintf("Restored %d file(s) before an error:\n\n%v", restored, err))
+					return
+				}
+				statusLabel.SetText(fmt.Sprintf("✓ Restored %d original file(s)", restored))
+			}()
+		},
+		win,
+	)
+}
+
+// confirmUnbackedOverwrite asks whether to update even though the backup
+// store is too full to keep the stock files in unbacked. Returns true to go
+// ahead.
+func confirmUnbackedOverwrite(win fyne.Window, unbacked []string) bool {
+	slog.Warn("backup store is full", "files", unbacked, "cap_mb", maxBackupBytes()/1024/1024)
+
+	list := strings.Join(unbacked, "\n")
+	if len(unbacked) > 10 {
+		list = strings.Join(unbacked[:10], "\n") + fmt.Sprintf("\n... and %d more", len(unbacked)-10)
+	}
+	message := fmt.Sprintf("The backup folder would go over its %d MB limit (max_backup_mb in %s), so these original files can't be backed up:\n\n%s\n\nIf you update, \"Restore Original Files\" won't be able to bring them back. Update anyway?",
+		maxBackupBytes()/1024/1024, configFile, list)
+
+	answer := make(chan bool)
+	dialog.ShowConfirm("Backup Folder Full", message, func(ok bool) {
+		answer <- ok
+	}, win)
+	return <-answer
+}
+
+func confirmUninstallPatch(win fyne.Window) {
+	dialog.ShowConfirm(
+		"Uninstall Patch",
+		"This restores every original file replaced by patches and removes all files the patcher added.\n\nYour game folder will be back to how it was before patching. Continue?",
+		func(uninstall bool) {
+			if !uninstall {
+				return
+			}
+			go func() {
+				setBusy(true)
+				defer setBusy(false)
+				playButton.Disable()
+				statusLabel.SetText("Uninstalling patch...")
+
+				restored, removed, err := uninstallPatch()
+				playButton.Enable()
+				if err != nil {
+					statusLabel.SetText("⚠️ Uninstall failed")
+					showError(win, fmt.Sprintf("Uninstall stopped after restoring %d and removing %d file(s):\n\n%v", restored, removed, err))
+					return
+				}
+				statusLabel.SetText(fmt.Sprintf("✓ Patch removed (%d restored, %d removed)", restored, removed))
+			}()
+		},
+		win,
+	)
+}
+
+// findFilesToUpdate returns the manifest files that are missing or differ
+// from the server copy. Hashes are taken from the verification cache when a
+// file's size and mtime are unchanged, unless full is set. progress, if not
+// nil, is called after each file is checked.
+func findFilesToUpdate(manifest *Manifest, full bool, progress func(done, total int)) []FileEntry {
+	toDownload := []FileEntry{}
+	for _, result := range verifyFiles(manifest, full, progress) {
+		if result.Status != statusOK {
+			toDownload = append(toDownload, result.File)
+		}
+	}
+	return toDownload
+}
+
 func launchGameOnly(win fyne.Window) {
 	playButton.Disable()
+
+	// Warn if the server is down before starting the game for nothing
+	if !confirmLaunchIfOffline(win) {
+		showRunningStatus()
+		playButton.Enable()
+		return
+	}
 	statusLabel.SetText("🎮 Launching EverQuest...")
 
+	// Stay open to watch the game, or add one more game to a multi-box
+	// session
+	if config.StayResident || runningInstances() > 0 {
+		err := checkBoxLimit(1)
+		if err == nil {
+			err = startInstance(win, findLaunchProfile(config.LaunchProfiles, config.ActiveLaunchProfile))
+		}
+		if err != nil {
+			showError(win, fmt.Sprintf("Failed to launch game: %v", err))
+		}
+		showRunningStatus()
+		playButton.Enable()
+		return
+	}
+
 	err := launchGame(config)
 	if err != nil {
 		showError(win, fmt.Sprintf("Failed to launch game: %v", err))
@@ -446,29 +846,7 @@ func performPatchAndLaunch(win fyne.Window) {
 	progressBar.SetValue(0.1)
 
 	// Check files
-	toDownload := []FileEntry{}
-	for _, file := range manifest.Files {
-		localPath := file.Path
-
-		// Check if file exists
-		info, err := os.Stat(localPath)
-		if os.IsNotExist(err) {
-			toDownload = append(toDownload, file)
-			continue
-		}
-
-		// Check size and hash
-		if info.Size() != file.Size {
-			toDownload = append(toDownload, file)
-			continue
-		}
-
-		localMD5, err := calculateMD5(localPath)
-		if err != nil || localMD5 != file.MD5 {
-			toDownload = append(toDownload, file)
-			continue
-		}
-	}
+	toDownload := findFilesToUpdate(manifest, false, nil)
 
 	// Download files if needed
 	if len(toDownload) > 0 {
@@ -539,7 +917,7 @@ func performPatchAndLaunch(win fyne.Window) {
 }
 
 func loadConfig() (*Config, error) {
-	data, err := os.ReadFile(configFile)
+	data, err := os.ReadFile(filepath.Join(launcherDir, configFile))
 	if err != nil {
 		return nil, err
 	}
@@ -564,38 +942,72 @@ func createDefaultConfig() *Config {
 		GameArgs:      "patchme",
 	}
 
-	data, _ := json.MarshalIndent(config, "", "  ")
-	os.WriteFile(configFile, data, 0644)
+	saveConfig(config)
 
 	return config
 }
 
+// saveConfig writes the configuration back to patcher-config.json, keeping
+// any settings this version doesn't know about
+func saveConfig(config *Config) error {
+	return writeConfigFile(filepath.Join(launcherDir, configFile), config)
+}
+
+// downloadManifest fetches manifest.json, reusing the cached copy if the
+// server says it hasn't changed
 func downloadManifest(serverURL string) (*Manifest, error) {
 	url := strings.TrimRight(serverURL, "/") + "/manifest.json"
 
-	resp, err := http.Get(url)
+	data, err := cachedGet(url)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
-	}
 
 	var manifest Manifest
-	err = json.NewDecoder(resp.Body).Decode(&manifest)
+	err = json.Unmarshal(data, &manifest)
 	if err != nil {
 		return nil, err
 	}
+	applyPackSelection(&manifest)
 
 	return &manifest, nil
 }
 
+// lastKnownManifest returns the manifest from the last successful check and
+// when that was, for showing status while the server is unreachable
+func lastKnownManifest(serverURL string) (*Manifest, time.Time, error) {
+	url := strings.TrimRight(serverURL, "/") + "/manifest.json"
+
+	data, checked, err := cachedCopy(url)
+	if err != nil {
+		return nil, checked, err
+	}
+
+	var manifest Manifest
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return nil, checked, err
+	}
+	applyPackSelection(&manifest)
+
+	return &manifest, checked, nil
+}
+
 func downloadFile(serverURL, filePath string) error {
+	return downloadFileTo(serverURL, filePath, filePath)
+}
+
+// downloadFileTo downloads the manifest file filePath from the server and
+// saves it as destPath
+func downloadFileTo(serverURL, filePath, destPath string) error {
+	// Never write outside the game folder, whatever the manifest says
+	if err := checkManifestPath(filePath); err != nil {
+		return err
+	}
+
 	url := strings.TrimRight(serverURL, "/") + "/" + filePath
 
-	resp, err := http.Get(url)
+	resp, err := httpGet(url)
 	if err != nil {
 		return err
 	}
@@ -605,7 +1017,7 @@ func downloadFile(serverURL, filePath string) error {
 		return fmt.Errorf("server returned status %d", resp.StatusCode)
 	}
 
-	dir := filepath.Dir(filePath)
+	dir := filepath.Dir(destPath)
 	if dir != "." {
 		err = os.MkdirAll(dir, 0755)
 		if err != nil {
@@ -613,20 +1025,20 @@ func downloadFile(serverURL, filePath string) error {
 		}
 	}
 
-	tmpFile := filePath + ".tmp"
+	tmpFile := destPath + ".tmp"
 	out, err := os.Create(tmpFile)
 	if err != nil {
 		return err
 	}
 
-	_, err = io.Copy(out, resp.Body)
+	_, err = io.Copy(out, newThrottledReader(resp.Body))
 	out.Close()
 	if err != nil {
 		os.Remove(tmpFile)
 		return err
 	}
 
-	err = os.Rename(tmpFile, filePath)
+	err = os.Rename(tmpFile, destPath)
 	if err != nil {
 		os.Remove(tmpFile)
 		return err
@@ -651,171 +1063,168 @@ func calculateMD5(filePath string) (string, error) {
 }
 
 func launchGame(config *Config) error {
-	// Get the directory where the launcher is located
-	exePath, err := os.Executable()
-	if err != nil {
-		return fmt.Errorf("could not determine launcher location: %v", err)
+	// LaunchPad exits after this, so the running game copies are the only
+	// way to see how many boxes are open
+	if err := checkBoxLimit(1); err != nil {
+		return err
 	}
-	launcherDir := filepath.Dir(exePath)
 
-	// Look for game exe in the same directory as the launcher
-	gameExePath := filepath.Join(launcherDir, config.GameExe)
-
-	// Check if game exe exists
-	if _, err := os.Stat(gameExePath); os.IsNotExist(err) {
-		return fmt.Errorf("game executable not found: %s\n\nMake sure %s is in the same folder as LaunchPad.exe", gameExePath, config.GameExe)
+	// Look for game exe in the active profile's game folder, started the
+	// way the selected launch profile says
+	launch := findLaunchProfile(config.LaunchProfiles, config.ActiveLaunchProfile)
+	cmd, err := gameCommand(config, gameFolder, launch)
+	if err != nil {
+		return err
 	}
-
-	args := []string{}
-	if config.GameArgs != "" {
-		args = strings.Fields(config.GameArgs)
+	restore, err := prepareLaunch(config, gameFolder, launch)
+	if err != nil {
+		return err
+	}
+	if launch != nil {
+		slog.Info("launching game", "exe", cmd.Path, "args", cmd.Args[1:], "dir", cmd.Dir, "launch_profile", launch.Name)
+	} else {
+		slog.Info("launching game", "exe", cmd.Path, "args", cmd.Args[1:], "dir", cmd.Dir)
 	}
 
-	cmd := exec.Command(gameExePath, args...)
-	// Set working directory to the game directory
-	cmd.Dir = launcherDir
-
+	// LaunchPad exits once the game is started; the next launch puts back
+	// the eqclient.ini settings the launch profile replaced
 	if runtime.GOOS == "windows" {
-		return cmd.Start()
+		err := cmd.Start()
+		if err != nil {
+			restore()
+		}
+		return err
 	}
 
+	defer restore()
 	return cmd.Run()
 }
 
-func checkLauncherUpdates(manifest *Manifest) bool {
-	launcherFiles := []string{"LaunchPad.exe", "patcher.exe", "patcher-config.json"}
+// checkLauncherUpdates returns the launcher binaries that differ from the
+// server copies. They are normally published in the manifest's launcher
+// section, but older manifests listed them with the game files.
+func checkLauncherUpdates(manifest *Manifest) []FileEntry {
+	outdated := []FileEntry{}
 
+	candidates := append([]FileEntry{}, manifest.Launcher...)
 	for _, file := range manifest.Files {
-		// Check if this is a launcher file
-		isLauncherFile := false
-		for _, lf := range launcherFiles {
-			if file.Path == lf {
-				isLauncherFile = true
-				break
-			}
+		if isLauncherBinary(file.Path) {
+			candidates = append(candidates, file)
 		}
+	}
 
-		if !isLauncherFile {
+	seen := make(map[string]bool)
+	for _, file := range candidates {
+		// Check if this is a launcher file
+		if !isLauncherBinary(file.Path) || seen[file.Path] {
 			continue
 		}
+		seen[file.Path] = true
 
-		// Get launcher directory
-		exePath, err := os.Executable()
+		if err := checkManifestPath(file.Path); err != nil {
+			continue
+		}
+		localPath, err := launcherBinaryPath(file.Path)
 		if err != nil {
 			continue
 		}
-		launcherDir := filepath.Dir(exePath)
-		localPath := filepath.Join(launcherDir, file.Path)
 
 		// Check if file exists
 		info, err := os.Stat(localPath)
 		if os.IsNotExist(err) {
-			return true // Launcher file missing
+			outdated = append(outdated, file) // Launcher file missing
+			continue
 		}
 
 		// Check size
-		if info.Size() != file.Size {
-			return true // Launcher file different size
+		if err != nil || info.Size() != file.Size {
+			outdated = append(outdated, file) // Launcher file different size
+			continue
 		}
 
 		// Check MD5
 		localMD5, err := calculateMD5(localPath)
 		if err != nil || localMD5 != file.MD5 {
-			return true // Launcher file different hash
+			outdated = append(outdated, file) // Launcher file different hash
 		}
 	}
 
-	return false
+	return outdated
 }
 
-// findObsoleteFiles finds files that were previously installed by the patcher but are no longer in the manifest
-func findObsoleteFiles(serverManifest *Manifest) []string {
-	obsolete := []string{}
-
-	// Load local manifest (tracks files we've previously downloaded)
-	localManifest := loadLocalManifest()
-	if localManifest == nil {
-		// No local manifest = first run, nothing to delete
-		return obsolete
-	}
+// Download limit choices offered in the launcher (label -> kbps)
+var speedLimitOptions = []struct {
+	Label string
+	Kbps  int
+}{
+	{"Unlimited", 0},
+	{"1 Mbps", 1000},
+	{"2 Mbps", 2000},
+	{"5 Mbps", 5000},
+	{"10 Mbps", 10000},
+	{"25 Mbps", 25000},
+	{"50 Mbps", 50000},
+}
 
-	// Launcher files that should never be deleted
-	launcherFiles := map[string]bool{
-		"LaunchPad.exe":        true,
-		"patcher.exe":          true,
-		"patcher-config.json":  true,
-		".patcher-manifest.json": true,
+// createSpeedLimitSelect builds the download limit dropdown. Changing it takes
+// effect immediately, even for downloads already in progress, and is saved to
+// patcher-config.json.
+func createSpeedLimitSelect() *widget.Select {
+	labels := []string{}
+	selected := ""
+	for _, opt := range speedLimitOptions {
+		labels = append(labels, opt.Label)
+		if opt.Kbps == config.MaxDownloadKbps {
+			selected = opt.Label
+		}
 	}
 
-	// Create a map of all files in server manifest for quick lookup
-	serverFiles := make(map[string]bool)
-	for _, file := range serverManifest.Files {
-		serverFiles[filepath.ToSlash(file.Path)] = true
+	// Keep a custom value from the config file selectable
+	if selected == "" {
+		selected = fmt.Sprintf("%d kbps", config.MaxDownloadKbps)
+		labels = append(labels, selected)
 	}
 
-	// Check each file we previously downloaded
-	for _, file := range localManifest.Files {
-		normalizedPath := filepath.ToSlash(file.Path)
-
-		// Skip launcher files
-		if launcherFiles[normalizedPath] {
-			continue
+	speedSelect := widget.NewSelect(labels, func(choice string) {
+		kbps := config.MaxDownloadKbps
+		for _, opt := range speedLimitOptions {
+			if opt.Label == choice {
+				kbps = opt.Kbps
+			}
 		}
-
-		// If file is not in server manifest, mark for deletion
-		if !serverFiles[normalizedPath] {
-			obsolete = append(obsolete, file.Path)
+		if kbps == downloadLimiter.Kbps() && kbps == config.MaxDownloadKbps {
+			return
 		}
-	}
 
-	return obsolete
-}
-
-// loadLocalManifest loads the local manifest that tracks files we've downloaded
-func loadLocalManifest() *Manifest {
-	data, err := os.ReadFile(localManifestFile)
-	if err != nil {
-		return nil
-	}
-
-	var manifest Manifest
-	err = json.Unmarshal(data, &manifest)
-	if err != nil {
-		return nil
-	}
-
-	return &manifest
-}
+		downloadLimiter.SetKbps(kbps)
+		config.MaxDownloadKbps = kbps
+		saveActiveConfig()
+	})
+	speedSelect.SetSelected(selected)
 
-// saveLocalManifest saves the current server manifest as our local record
-func saveLocalManifest(manifest *Manifest) {
-	data, err := json.MarshalIndent(manifest, "", "  ")
-	if err != nil {
-		return
-	}
-	os.WriteFile(localManifestFile, data, 0644)
+	return speedSelect
 }
 
 func showError(win fyne.Window, message string) {
 	dialog.ShowError(fmt.Errorf("%s", message), win)
 }
 
-// downloadNews fetches the news.json from the server
+// downloadNews fetches news.json, falling back to the last copy we saw if
+// the server can't be reached
 func downloadNews(serverURL string) (*NewsConfig, error) {
 	url := strings.TrimRight(serverURL, "/") + "/news.json"
 
-	resp, err := http.Get(url)
+	data, err := cachedGet(url)
 	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+		cached, _, cacheErr := cachedCopy(url)
+		if cacheErr != nil {
+			return nil, err
+		}
+		data = cached
 	}
 
 	var newsConfig NewsConfig
-	err = json.NewDecoder(resp.Body).Decode(&newsConfig)
+	err = json.Unmarshal(data, &newsConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -823,16 +1232,38 @@ func downloadNews(serverURL string) (*NewsConfig, error) {
 	return &newsConfig, nil
 }
 
-// createNewsFader creates a rotating news fader widget
-func createNewsFader(serverURL string) *canvas.Text {
-	newsLabel := canvas.NewText("", theme.ForegroundColor())
+// newsLabel shows the rotating news from news.json
+var newsLabel *canvas.Text
+
+// newsGeneration is bumped whenever the news is reloaded, so the rotation
+// for the previous server stops
+var newsGeneration int64
+
+// createNewsFader creates a rotating news fader widget; loadNews fills it
+func createNewsFader() *canvas.Text {
+	newsLabel = canvas.NewText("", theme.ForegroundColor())
 	newsLabel.TextSize = 14
 	newsLabel.Alignment = fyne.TextAlignCenter
 	newsLabel.TextStyle = fyne.TextStyle{Italic: true}
 
+	return newsLabel
+}
+
+// loadNews downloads news.json from serverURL and starts rotating through
+// it, replacing any news already showing
+func loadNews(serverURL string) {
+	generation := atomic.AddInt64(&newsGeneration, 1)
+
+	newsLabel.Text = ""
+	newsLabel.Color = theme.ForegroundColor()
+	newsLabel.Refresh()
+
 	// Try to download news
 	go func() {
 		newsConfig, err := downloadNews(serverURL)
+		if atomic.LoadInt64(&newsGeneration) != generation {
+			return // Switched server in the meantime
+		}
 		if err != nil || newsConfig == nil || len(newsConfig.Items) == 0 || !newsConfig.Enabled {
 			// No news or error - hide the label
 			newsLabel.Text = ""
@@ -859,7 +1290,11 @@ func createNewsFader(serverURL string) *canvas.Text {
 		// Rotate through items using a ticker
 		ticker := time.NewTicker(time.Duration(rotationTime) * time.Second)
 		go func() {
+			defer ticker.Stop()
 			for range ticker.C {
+				if atomic.LoadInt64(&newsGeneration) != generation {
+					return
+				}
 				currentIndex = (currentIndex + 1) % len(newsConfig.Items)
 				item := newsConfig.Items[currentIndex]
 
@@ -873,8 +1308,6 @@ func createNewsFader(serverURL string) *canvas.Text {
 			}
 		}()
 	}()
-
-	return newsLabel
 }
 
 // parseHexColor converts a hex color string to color.Color
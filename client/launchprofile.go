@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LaunchProfile is one entry in the "launch_profiles" list of
+// patcher-config.json: a named way of starting the game, e.g. windowed on a
+// second login server. Without launch profiles game_args is used.
+type LaunchProfile struct {
+	Name       string            `json:"name"`
+	Args       []string          `json:"args,omitempty"`        // one entry per argument, no quoting needed
+	WorkingDir string            `json:"working_dir,omitempty"` // absolute, or relative to the game folder
+	Env        map[string]string `json:"env,omitempty"`         // added to LaunchPad's environment
+
+	// eqclient.ini settings (section -> key -> value) written to the
+	// working folder's eqclient.ini before launching
+	INI map[string]map[string]string `json:"eqclient_ini,omitempty"`
+}
+
+// MultiboxConfig is the last multi-box launch in LaunchPad, offered again
+// next time
+type MultiboxConfig struct {
+	Instances    []string `json:"instances"`               // launch profile of each game, "" for the default
+	DelaySeconds int      `json:"delay_seconds,omitempty"` // wait between starts, 0 = default
+}
+
+// findLaunchProfile returns the launch profile called name, or the first
+// one if name is empty. Returns nil if there are none or name isn't one.
+func findLaunchProfile(profiles []LaunchProfile, name string) *LaunchProfile {
+	if len(profiles) == 0 {
+		return nil
+	}
+	if name == "" {
+		return &profiles[0]
+	}
+	for i := range profiles {
+		if profiles[i].Name == name {
+			return &profiles[i]
+		}
+	}
+	return nil
+}
+
+// launchProfileNames lists the launch profiles in config file order
+func launchProfileNames(profiles []LaunchProfile) []string {
+	names := make([]string, 0, len(profiles))
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// splitArgs splits game_args on spaces, keeping "quoted parts" together
+func splitArgs(s string) []string {
+	args := []string{}
+	var current strings.Builder
+	inQuotes, started := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			started = true
+		case (r == ' ' || r == '\t') && !inQuotes:
+			if started {
+				args = append(args, current.String())
+				current.Reset()
+				started = false
+			}
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// launchDir is the folder the game starts in: lp's working_dir if set,
+// else the game folder
+func launchDir(gameFolder string, lp *LaunchProfile) string {
+	if lp != nil && lp.WorkingDir != "" {
+		return resolveDir(lp.WorkingDir, gameFolder)
+	}
+	return gameFolder
+}
+
+// gameCommand builds the command that starts cfg's game from gameFolder,
+// using lp's arguments, folder and environment if lp is not nil (game_args
+// otherwise). Nothing on disk is changed; prepareLaunch does that.
+func gameCommand(cfg *Config, gameFolder string, lp *LaunchProfile) (*exec.Cmd, error) {
+	exePath := filepath.Join(gameFolder, cfg.GameExe)
+	if _, err := os.Stat(exePath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("game executable not found: %s\n\nMake sure %s is in the game folder", exePath, cfg.GameExe)
+	}
+
+	args := splitArgs(cfg.GameArgs)
+	var env []string
+	if lp != nil {
+		if lp.Args != nil {
+			args = lp.Args
+		}
+		if len(lp.Env) > 0 {
+			env = os.Environ()
+			keys := make([]string, 0, len(lp.Env))
+			for key := range lp.Env {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				env = append(env, key+"="+lp.Env[key])
+			}
+		}
+	}
+
+	cmd := exec.Command(exePath, args...)
+	cmd.Dir = launchDir(gameFolder, lp)
+	cmd.Env = env // nil means the launcher's own environment
+	return cmd, nil
+}
+
+// prepareLaunch gets gameFolder ready for starting the game with lp: lp's
+// eqclient.ini settings are written, eqhost.txt is checked and cfg's
+// pre_launch hooks run. The returned restore puts the eqclient.ini settings
+// back and should be called once the game has exited. When the launcher
+// doesn't wait for the game, the next launch puts them back first, so a
+// profile never inherits another's settings.
+func prepareLaunch(cfg *Config, gameFolder string, lp *LaunchProfile) (restore func(), err error) {
+	dir := launchDir(gameFolder, lp)
+	path := filepath.Join(dir, "eqclient.ini")
+	restore = func() {}
+
+	if lp != nil && len(lp.INI) > 0 {
+		undo, err := applyINIOverrides(path, lp.INI)
+		if err != nil {
+			return nil, fmt.Errorf("could not apply the %s settings to eqclient.ini: %v", lp.Name, err)
+		}
+		restore = func() {
+			if err := undo(); err != nil {
+				slog.Warn("could not restore eqclient.ini", "path", path, "error", err)
+			}
+		}
+	} else if err := restoreINIOverrides(path); err != nil {
+		return nil, fmt.Errorf("could not put back the eqclient.ini settings of the last launch: %v", err)
+	}
+
+	// The game reads eqhost.txt from the folder it starts in
+	if _, err := ensureEQHost(dir, eqhostServers(cfg)); err != nil {
+		restore()
+		return nil, err
+	}
+
+	if err := runHooks(hookPreLaunch, cfg, gameFolder); err != nil {
+		restore()
+		return nil, err
+	}
+	return restore, nil
+}
+
+// iniOverride is one eqclient.ini setting changed for a launch, with what
+// it was before
+type iniOverride struct {
+	Section  string `json:"section"`
+	Key      string `json:"key"`
+	Value    string `json:"value"`              // the launch profile's value
+	Previous string `json:"previous,omitempty"` // the value before the launch
+	Present  bool   `json:"present"`            // false if the key wasn't set before
+}
+
+// iniOverrideRecord is kept next to eqclient.ini while a launch profile's
+// settings are in it, so they can be put back after the launcher has exited
+type iniOverrideRecord struct {
+	Backup   bool          `json:"backup"` // eqclient.ini.bak was made for this launch
+	Settings []iniOverride `json:"settings"`
+}
+
+func iniOverrideRecordFile(path string) string {
+	return path + ".overrides.json"
+}
+
+// applyINIOverrides sets the given keys in the ini file at path, leaving
+// everything else as it is. Settings left by an earlier launch are put back
+// first. If anything changes, the file is copied to a .bak next to it,
+// unless a .bak is already there. The returned undo is restoreINIOverrides.
+func applyINIOverrides(path string, overrides map[string]map[string]string) (undo func() error, err error) {
+	if err := restoreINIOverrides(path); err != nil {
+		return nil, fmt.Errorf("could not put back the settings of the last launch: %v", err)
+	}
+	ini, err := LoadINI(path)
+	if err != nil {
+		return nil, err
+	}
+
+	record := iniOverrideRecord{}
+	for section, keys := range overrides {
+		for key, value := range keys {
+			old, present := ini.sections[section][key]
+			if present && old == value {
+				continue
+			}
+			record.Settings = append(record.Settings, iniOverride{section, key, value, old, present})
+			ini.Set(section, key, value)
+		}
+	}
+	if len(record.Settings) == 0 {
+		return func() error { return nil }, nil
+	}
+
+	// An existing .bak may be the only copy of the player's own settings
+	if _, err := os.Stat(path); err == nil {
+		if _, err := os.Stat(path + ".bak"); os.IsNotExist(err) {
+			if err := copyFile(path, path+".bak"); err != nil {
+				return nil, fmt.Errorf("could not back up %s: %v", filepath.Base(path), err)
+			}
+			record.Backup = true
+		}
+	}
+
+	// The record goes first, so the settings can always be put back
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(iniOverrideRecordFile(path), data); err != nil {
+		return nil, err
+	}
+	if err := ini.Save(); err != nil {
+		return nil, err
+	}
+	return func() error { return restoreINIOverrides(path) }, nil
+}
+
+// restoreINIOverrides puts back the settings recorded by applyINIOverrides,
+// except ones the game has changed since, then removes the record and the
+// .bak made for that launch. Does nothing if there is no record.
+func restoreINIOverrides(path string) error {
+	recordFile := iniOverrideRecordFile(path)
+	data, err := os.ReadFile(recordFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var record iniOverrideRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		// eqclient.ini.bak, if any, still has the old settings
+		slog.Warn("damaged eqclient.ini override record, settings not put back", "path", recordFile, "error", err)
+		return os.Remove(recordFile)
+	}
+
+	ini, err := LoadINI(path)
+	if err != nil {
+		return err
+	}
+	for _, o := range record.Settings {
+		if current, ok := ini.sections[o.Section][o.Key]; !ok || current != o.Value {
+			continue // Changed in the game since - keep the player's choice
+		}
+		if o.Present {
+			ini.Set(o.Section, o.Key, o.Previous)
+		} else {
+			ini.Delete(o.Section, o.Key)
+		}
+	}
+	if err := ini.Save(); err != nil {
+		return err
+	}
+	if record.Backup {
+		os.Remove(path + ".bak")
+	}
+	slog.Info("eqclient.ini settings put back", "path", path, "settings", len(record.Settings))
+	return os.Remove(recordFile)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", []string{}},
+		{"   ", []string{}},
+		{"patchme", []string{"patchme"}},
+		{"patchme  /login:bob", []string{"patchme", "/login:bob"}},
+		{"\tpatchme\t-a ", []string{"patchme", "-a"}},
+		{`"C:\Program Files\EQ" patchme`, []string{`C:\Program Files\EQ`, "patchme"}},
+		{`/ini:"my settings.ini"`, []string{"/ini:my settings.ini"}},
+		{`"" patchme`, []string{"", "patchme"}},
+	}
+	for _, tt := range tests {
+		if got := splitArgs(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestApplyINIOverrides(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  string // "" = no eqclient.ini
+		overrides map[string]map[string]string
+		inGame    map[string]map[string]string // changed by the player while playing
+		during    map[string]map[string]string
+		after     map[string]map[string]string // "" = key must be gone
+		oldBak    string                       // eqclient.ini.bak left from before
+		wantBak   bool
+	}{
+		{
+			name:      "values restored",
+			existing:  "[Defaults]\nWindowedMode=FALSE\nSound=TRUE\n",
+			overrides: map[string]map[string]string{"Defaults": {"WindowedMode": "TRUE", "WindowX": "0"}},
+			during:    map[string]map[string]string{"Defaults": {"WindowedMode": "TRUE", "WindowX": "0", "Sound": "TRUE"}},
+			after:     map[string]map[string]string{"Defaults": {"WindowedMode": "FALSE", "WindowX": "", "Sound": "TRUE"}},
+			wantBak:   true,
+		},
+		{
+			name:      "nothing to change",
+			existing:  "[Defaults]\nWindowedMode=TRUE\n",
+			overrides: map[string]map[string]string{"Defaults": {"WindowedMode": "TRUE"}},
+			during:    map[string]map[string]string{"Defaults": {"WindowedMode": "TRUE"}},
+			after:     map[string]map[string]string{"Defaults": {"WindowedMode": "TRUE"}},
+		},
+		{
+			name:      "player's change kept",
+			existing:  "[Defaults]\nWindowedMode=FALSE\nWindowX=10\n",
+			overrides: map[string]map[string]string{"Defaults": {"WindowedMode": "TRUE", "WindowX": "0"}},
+			inGame:    map[string]map[string]string{"Defaults": {"WindowX": "250"}},
+			after:     map[string]map[string]string{"Defaults": {"WindowedMode": "FALSE", "WindowX": "250"}},
+			wantBak:   true,
+		},
+		{
+			name:      "existing backup kept",
+			existing:  "[Defaults]\nWindowedMode=FALSE\n",
+			overrides: map[string]map[string]string{"Defaults": {"WindowedMode": "TRUE"}},
+			during:    map[string]map[string]string{"Defaults": {"WindowedMode": "TRUE"}},
+			after:     map[string]map[string]string{"Defaults": {"WindowedMode": "FALSE"}},
+			oldBak:    "[Defaults]\nWindowedMode=FALSE\nSound=FALSE\n",
+			wantBak:   true,
+		},
+		{
+			name:      "missing file",
+			overrides: map[string]map[string]string{"VideoMode": {"Width": "1920"}},
+			during:    map[string]map[string]string{"VideoMode": {"Width": "1920"}},
+			after:     map[string]map[string]string{"VideoMode": {"Width": ""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "eqclient.ini")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tt.oldBak != "" {
+				if err := os.WriteFile(path+".bak", []byte(tt.oldBak), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			undo, err := applyINIOverrides(path, tt.overrides)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkINI(t, path, tt.during)
+			if _, err := os.Stat(path + ".bak"); (err == nil) != tt.wantBak {
+				t.Errorf("eqclient.ini.bak exists: %v, want %v", err == nil, tt.wantBak)
+			}
+
+			if tt.inGame != nil {
+				ini, err := LoadINI(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for section, keys := range tt.inGame {
+					for key, value := range keys {
+						ini.Set(section, key, value)
+					}
+				}
+				if err := ini.Save(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := undo(); err != nil {
+				t.Fatal(err)
+			}
+			checkINI(t, path, tt.after)
+
+			if tt.oldBak != "" {
+				if bak, _ := os.ReadFile(path + ".bak"); string(bak) != tt.oldBak {
+					t.Errorf("eqclient.ini.bak = %q, want the old one kept", bak)
+				}
+			} else if _, err := os.Stat(path + ".bak"); err == nil {
+				t.Error("eqclient.ini.bak left behind")
+			}
+			if _, err := os.Stat(iniOverrideRecordFile(path)); err == nil {
+				t.Error("override record left behind")
+			}
+		})
+	}
+}
+
+func TestPrepareLaunchAfterUnwaitedLaunch(t *testing.T) {
+	gameFolder := t.TempDir()
+	path := filepath.Join(gameFolder, "eqclient.ini")
+	if err := os.WriteFile(path, []byte("[Defaults]\nWindowedMode=FALSE\nWindowX=10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{}
+	windowed := &LaunchProfile{Name: "Windowed", INI: map[string]map[string]string{"Defaults": {"WindowedMode": "TRUE", "WindowX": "0"}}}
+	second := &LaunchProfile{Name: "Second screen", INI: map[string]map[string]string{"Defaults": {"WindowX": "1920", "WindowY": "0"}}}
+
+	// Neither launch waits for the game, as on Windows, so restore isn't called
+	tests := []struct {
+		lp   *LaunchProfile
+		want map[string]map[string]string
+	}{
+		{windowed, map[string]map[string]string{"Defaults": {"WindowedMode": "TRUE", "WindowX": "0", "WindowY": ""}}},
+		{second, map[string]map[string]string{"Defaults": {"WindowedMode": "FALSE", "WindowX": "1920", "WindowY": "0"}}},
+		{second, map[string]map[string]string{"Defaults": {"WindowedMode": "FALSE", "WindowX": "1920", "WindowY": "0"}}},
+		{windowed, map[string]map[string]string{"Defaults": {"WindowedMode": "TRUE", "WindowX": "0", "WindowY": ""}}},
+		{nil, map[string]map[string]string{"Defaults": {"WindowedMode": "FALSE", "WindowX": "10", "WindowY": ""}}},
+	}
+	for i, tt := range tests {
+		if _, err := prepareLaunch(cfg, gameFolder, tt.lp); err != nil {
+			t.Fatalf("launch %d: %v", i+1, err)
+		}
+		checkINI(t, path, tt.want)
+
+		// The backup always holds the player's own settings
+		if _, err := os.Stat(path + ".bak"); err == nil {
+			checkINI(t, path+".bak", map[string]map[string]string{"Defaults": {"WindowedMode": "FALSE", "WindowX": "10", "WindowY": ""}})
+		}
+	}
+	for _, leftover := range []string{path + ".bak", iniOverrideRecordFile(path)} {
+		if _, err := os.Stat(leftover); err == nil {
+			t.Errorf("%s left behind after a launch without overrides", filepath.Base(leftover))
+		}
+	}
+}
+
+// checkINI compares the values in the INI file at path with want; ""
+// means the key must not be set
+func checkINI(t *testing.T, path string, want map[string]map[string]string) {
+	t.Helper()
+	ini, err := LoadINI(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for section, keys := range want {
+		for key, value := range keys {
+			got, ok := ini.sections[section][key]
+			if value == "" && ok {
+				t.Errorf("[%s] %s = %q, want it unset", section, key, got)
+			} else if value != "" && got != value {
+				t.Errorf("[%s] %s = %q, want %q", section, key, got, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Local record of the last manifest we patched to, used to find obsolete files
+const localManifestFile = ".patcher-manifest.json"
+
+// Launcher binaries that are replaced by the self-updater
+var launcherBinaries = map[string]bool{
+	"LaunchPad.exe": true,
+	"patcher.exe":   true,
+}
+
+// isLauncherBinary reports whether a manifest path is a launcher binary
+func isLauncherBinary(path string) bool {
+	return launcherBinaries[filepath.ToSlash(path)]
+}
+
+// findObsoleteFiles finds files that were previously installed by the patcher but are no longer in the manifest
+func findObsoleteFiles(serverManifest *Manifest) []string {
+	obsolete := []string{}
+
+	// Load local manifest (tracks files we've previously downloaded)
+	localManifest := loadLocalManifest()
+	if localManifest == nil {
+		// No local manifest = first run, nothing to delete
+		return obsolete
+	}
+
+	// Launcher files that should never be deleted
+	launcherFiles := map[string]bool{
+		"LaunchPad.exe":          true,
+		"patcher.exe":            true,
+		"patcher-config.json":    true,
+		".patcher-manifest.json": true,
+	}
+
+	// Create a map of all files in server manifest for quick lookup
+	serverFiles := make(map[string]bool)
+	for _, file := range serverManifest.Files {
+		serverFiles[filepath.ToSlash(file.Path)] = true
+	}
+
+	// Check each file we previously downloaded
+	for _, file := range localManifest.Files {
+		normalizedPath := filepath.ToSlash(file.Path)
+
+		// Skip launcher files
+		if launcherFiles[normalizedPath] {
+			continue
+		}
+
+		// Never delete anything outside the game folder
+		if err := checkManifestPath(file.Path); err != nil {
+			continue
+		}
+
+		// If file is not in server manifest, mark for deletion
+		if !serverFiles[normalizedPath] {
+			obsolete = append(obsolete, file.Path)
+		}
+	}
+
+	return obsolete
+}
+
+// withKeptObsolete returns manifest plus the local manifest's entries for
+// obsolete files that aren't being deleted, so files the player chose to
+// keep (e.g. by unticking them in Verify & Repair) are still tracked and
+// offered for removal next time
+func withKeptObsolete(manifest *Manifest, toDelete []string) *Manifest {
+	deleting := make(map[string]bool)
+	for _, path := range toDelete {
+		deleting[filepath.ToSlash(path)] = true
+	}
+	kept := make(map[string]bool)
+	for _, path := range findObsoleteFiles(manifest) {
+		if _, err := os.Stat(path); err == nil && !deleting[filepath.ToSlash(path)] {
+			kept[filepath.ToSlash(path)] = true
+		}
+	}
+	if len(kept) == 0 {
+		return manifest
+	}
+
+	saved := *manifest
+	saved.Files = append([]FileEntry{}, manifest.Files...)
+	for _, file := range loadLocalManifest().Files {
+		if kept[filepath.ToSlash(file.Path)] {
+			saved.Files = append(saved.Files, file)
+		}
+	}
+	return &saved
+}
+
+// loadLocalManifest loads the local manifest that tracks files we've downloaded
+func loadLocalManifest() *Manifest {
+	data, err := os.ReadFile(localManifestFile)
+	if err != nil {
+		return nil
+	}
+
+	var manifest Manifest
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return nil
+	}
+
+	return &manifest
+}
+
+// installedFile is what the patcher last knew to be on disk for one file:
+// the hash, and the modification time the file had then. A file whose
+// modification time has changed since was edited by the player; one that
+// differs without a new modification time is damaged.
+type installedFile struct {
+	MD5     string `json:"md5"`
+	ModTime int64  `json:"mtime"` // UnixNano
+}
+
+// saveLocalManifest saves the current server manifest as our local record.
+// matching lists the files known to match it on disk right now, because
+// they were just installed or verified; their modification times are
+// recorded. Other files keep what was recorded for them before.
+func saveLocalManifest(manifest *Manifest, matching []string) {
+	saved := *manifest
+	saved.Installed = make(map[string]installedFile)
+	if local := loadLocalManifest(); local != nil {
+		for path, file := range local.Installed {
+			saved.Installed[path] = file
+		}
+	}
+
+	inManifest := make(map[string]string)
+	for _, file := range manifest.Files {
+		inManifest[filepath.ToSlash(file.Path)] = file.MD5
+	}
+	for _, path := range matching {
+		path = filepath.ToSlash(path)
+		if info, err := os.Stat(path); err == nil && inManifest[path] != "" {
+			saved.Installed[path] = installedFile{MD5: inManifest[path], ModTime: info.ModTime().UnixNano()}
+		}
+	}
+	for path := range saved.Installed {
+		if _, ok := inManifest[path]; !ok {
+			delete(saved.Installed, path) // No longer tracked
+		}
+	}
+
+	data, err := json.MarshalIndent(&saved, "", "  ")
+	if err != nil {
+		return
+	}
+	os.WriteFile(localManifestFile, data, 0644)
+}
+
+// manifestPaths lists the paths of every file in manifest
+func manifestPaths(manifest *Manifest) []string {
+	paths := make([]string, 0, len(manifest.Files))
+	for _, file := range manifest.Files {
+		paths = append(paths, file.Path)
+	}
+	return paths
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWithKeptObsolete(t *testing.T) {
+	local := &Manifest{Version: "1", Files: []FileEntry{
+		{Path: "a.txt", MD5: "a1"},
+		{Path: "old/kept.txt", MD5: "k1"},
+		{Path: "old/deleted.txt", MD5: "d1"},
+		{Path: "old/missing.txt", MD5: "m1"},
+		{Path: "../outside.txt", MD5: "o1"},
+	}}
+	server := &Manifest{Version: "2", Files: []FileEntry{{Path: "a.txt", MD5: "a2"}}}
+
+	tests := []struct {
+		name     string
+		local    *Manifest
+		onDisk   []string
+		toDelete []string
+		want     []string // path:md5
+	}{
+		{
+			name:  "first run",
+			local: nil,
+			want:  []string{"a.txt:a2"},
+		},
+		{
+			name:     "everything obsolete deleted",
+			local:    local,
+			onDisk:   []string{"old/kept.txt", "old/deleted.txt"},
+			toDelete: []string{"old/kept.txt", "old/deleted.txt"},
+			want:     []string{"a.txt:a2"},
+		},
+		{
+			name:     "kept file still tracked",
+			local:    local,
+			onDisk:   []string{"old/kept.txt", "old/deleted.txt"},
+			toDelete: []string{"old/deleted.txt"},
+			want:     []string{"a.txt:a2", "old/kept.txt:k1"},
+		},
+		{
+			name:   "files already gone are dropped",
+			local:  local,
+			onDisk: []string{},
+			want:   []string{"a.txt:a2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inTempGameFolder(t)
+			if tt.local != nil {
+				saveLocalManifest(tt.local, nil)
+			}
+			files := map[string]string{}
+			for _, path := range tt.onDisk {
+				files[path] = "x"
+			}
+			writeFiles(t, files)
+
+			saved := withKeptObsolete(server, tt.toDelete)
+			got := []string{}
+			for _, file := range saved.Files {
+				got = append(got, file.Path+":"+file.MD5)
+			}
+			sort.Strings(got)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("withKeptObsolete() files = %v, want %v", got, tt.want)
+			}
+			if saved.Version != "2" {
+				t.Errorf("version = %q, want the server's", saved.Version)
+			}
+		})
+	}
+	if len(server.Files) != 1 {
+		t.Errorf("server manifest was changed: %v", server.Files)
+	}
+}
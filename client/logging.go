@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	logDirName     = "logs"
+	logMaxBytes    = 5 * 1024 * 1024 // rotate at 5 MB
+	logKeepBackups = 3               // name.log.1 ... name.log.3
+)
+
+// rotatingWriter is a log file that is renamed to name.1 (pushing older
+// ones along) once it reaches maxBytes
+type rotatingWriter struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	backups  int
+	file     *os.File
+	size     int64
+}
+
+func newRotatingWriter(path string, maxBytes int64, backups int) (*rotatingWriter, error) {
+	w := &rotatingWriter{path: path, maxBytes: maxBytes, backups: backups}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.size > 0 && w.size+int64(len(p)) > w.maxBytes {
+		w.rotate()
+	}
+	if w.file == nil {
+		return 0, fmt.Errorf("log file %s is not open", w.path)
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate shifts name.log -> name.log.1 -> name.log.2 ..., dropping the
+// oldest. If reopening fails, logging stops rather than failing the caller.
+func (w *rotatingWriter) rotate() {
+	w.file.Close()
+	w.file = nil
+
+	os.Remove(fmt.Sprintf("%s.%d", w.path, w.backups))
+	for i := w.backups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+	}
+	os.Rename(w.path, w.path+".1")
+
+	w.open()
+}
+
+// parseLogLevel reads log_level from the config: debug, info (default),
+// warn or error
+func parseLogLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log_level %q (use debug, info, warn or error)", level)
+}
+
+// logLevel is the active level; setupLogging sets it and changing it takes
+// effect straight away
+var logLevel = new(slog.LevelVar)
+
+// setupLogging sends slog (and the standard log package) to dir/name with
+// size-based rotation. Without a log file everything still goes to stderr.
+func setupLogging(dir, name, level string) error {
+	lvl, levelErr := parseLogLevel(level)
+	logLevel.Set(lvl)
+
+	var out io.Writer = os.Stderr
+	err := os.MkdirAll(dir, 0755)
+	if err == nil {
+		var w *rotatingWriter
+		w, err = newRotatingWriter(filepath.Join(dir, name), logMaxBytes, logKeepBackups)
+		if err == nil {
+			out = w
+		}
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: logLevel, ReplaceAttr: redactLogAttr})))
+
+	if err != nil {
+		return err
+	}
+	return levelErr
+}
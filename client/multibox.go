@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Multi-boxing: LaunchPad starts several copies of the game one after the
+// other and stays open, keeping track of them so the server's box limit
+// (max_boxes in server-config.json) holds across launches.
+
+const (
+	defaultBoxDelay = 5 // seconds between starts, so logins don't collide
+	maxBoxesNoLimit = 8 // most instances offered when the server sets no limit
+)
+
+// gameInstance is a running copy of the game started by LaunchPad
+type gameInstance struct {
+	cmd     *exec.Cmd
+	launch  string
+	started time.Time
+}
+
+var (
+	instancesMu sync.Mutex
+	instances   []*gameInstance
+)
+
+// runningInstances is the number of games LaunchPad started that are still
+// running
+func runningInstances() int {
+	instancesMu.Lock()
+	defer instancesMu.Unlock()
+	return len(instances)
+}
+
+// startInstance starts the game with launch profile lp (nil for game_args)
+// and watches it until it exits
+func startInstance(win fyne.Window, lp *LaunchProfile) error {
+	cmd, err := gameCommand(config, gameFolder, lp)
+	if err != nil {
+		return err
+	}
+	restore, err := prepareLaunch(config, gameFolder, lp)
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		restore()
+		return err
+	}
+
+	inst := &gameInstance{cmd: cmd, started: time.Now()}
+	if lp != nil {
+		inst.launch = lp.Name
+	}
+	instancesMu.Lock()
+	instances = append(instances, inst)
+	instancesMu.Unlock()
+	slog.Info("game started", "pid", cmd.Process.Pid, "launch_profile", inst.launch, "running", runningInstances())
+
+	go func() {
+		err := cmd.Wait()
+		restore()
+
+		instancesMu.Lock()
+		for i, other := range instances {
+			if other == inst {
+				instances = append(instances[:i], instances[i+1:]...)
+				break
+			}
+		}
+		instancesMu.Unlock()
+
+		if err != nil {
+			slog.Debug("game wait", "pid", cmd.Process.Pid, "error", err)
+		}
+		gameExited(win, &gameSession{
+			Launch:   inst.launch,
+			Started:  inst.started,
+			Duration: time.Since(inst.started),
+			ExitCode: cmd.ProcessState.ExitCode(),
+		})
+	}()
+	return nil
+}
+
+// showRunningStatus shows how many games are running
+func showRunningStatus() {
+	if n := runningInstances(); n > 0 {
+		statusLabel.SetText(fmt.Sprintf("🎮 %d game(s) running", n))
+	} else {
+		statusLabel.SetText("Ready to play")
+	}
+}
+
+// boxLimit is the server's max_boxes, 0 if it sets none. The copy of
+// server-config.json saved by the last update check is used, so the limit
+// still holds while the patch server is down; it is only downloaded here if
+// there is no copy yet.
+func boxLimit() int {
+	serverConfig, err := cachedServerConfig(config.ServerURL)
+	if err != nil {
+		serverConfig, err = downloadServerConfig(config.ServerURL)
+	}
+	if err != nil {
+		slog.Warn("could not read server config, box limit not enforced", "error", err)
+		return 0
+	}
+	return serverConfig.MaxBoxes
+}
+
+// checkBoxLimit returns an error if starting count more games would go over
+// the server's box limit. Every running copy of the game counts, not just
+// the ones LaunchPad started.
+func checkBoxLimit(count int) error {
+	limit := boxLimit()
+	if limit <= 0 {
+		return nil
+	}
+
+	running := runningInstances()
+	if n, err := countProcesses(filepath.Base(config.GameExe)); err != nil {
+		slog.Warn("could not count running games", "error", err)
+	} else if n > running {
+		running = n
+	}
+	if running+count > limit {
+		return fmt.Errorf("%s allows at most %d game(s) at once.\n\n%d already running.", serverDisplayName(), limit, running)
+	}
+	return nil
+}
+
+// countProcesses counts the running processes whose executable is called
+// exeName, including ones LaunchPad didn't start
+func countProcesses(exeName string) (int, error) {
+	snapshot, err := syscall.CreateToolhelp32Snapshot(syscall.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer syscall.CloseHandle(snapshot)
+
+	entry := syscall.ProcessEntry32{}
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	count := 0
+	for err = syscall.Process32First(snapshot, &entry); err == nil; err = syscall.Process32Next(snapshot, &entry) {
+		if strings.EqualFold(syscall.UTF16ToString(entry.ExeFile[:]), exeName) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// showMultiboxDialog asks how many games to start and with which launch
+// profiles, starting from the last multi-box launch
+func showMultiboxDialog(win fyne.Window) {
+	limit := boxLimit()
+	most := maxBoxesNoLimit
+	if limit > 0 {
+		most = limit
+	}
+	if most < 2 {
+		dialog.ShowInformation("Multi-Box Launch", fmt.Sprintf("%s allows only one game at a time.", serverDisplayName()), win)
+		return
+	}
+
+	last := MultiboxConfig{Instances: []string{"", ""}, DelaySeconds: defaultBoxDelay}
+	if config.Multibox != nil && len(config.Multibox.Instances) > 0 {
+		last = *config.Multibox
+	}
+	if last.DelaySeconds <= 0 {
+		last.DelaySeconds = defaultBoxDelay // 0 means the default, not no wait
+	}
+	choices := append([]string{}, last.Instances...)
+
+	names := launchProfileNames(config.LaunchProfiles)
+	rows := container.NewVBox()
+	buildRows := func(count int) {
+		for len(choices) < count {
+			choices = append(choices, "")
+		}
+		choices = choices[:count]
+
+		rows.Objects = nil
+		for i := range choices {
+			i := i
+			label := widget.NewLabel(fmt.Sprintf("Game %d:", i+1))
+			if len(names) == 0 {
+				rows.Add(container.NewHBox(label, widget.NewLabel("game_args")))
+				continue
+			}
+			launchSelect := widget.NewSelect(names, func(name string) { choices[i] = name })
+			if p := findLaunchProfile(config.LaunchProfiles, choices[i]); p != nil {
+				launchSelect.SetSelected(p.Name)
+			}
+			rows.Add(container.NewBorder(nil, nil, label, nil, launchSelect))
+		}
+		rows.Refresh()
+	}
+
+	counts := []string{}
+	for n := 2; n <= most; n++ {
+		counts = append(counts, strconv.Itoa(n))
+	}
+	count := len(choices)
+	if count < 2 {
+		count = 2
+	} else if count > most {
+		count = most
+	}
+	countSelect := widget.NewSelect(counts, func(value string) {
+		n, _ := strconv.Atoi(value)
+		buildRows(n)
+	})
+	countSelect.SetSelected(strconv.Itoa(count))
+
+	delayEntry := widget.NewEntry()
+	delayEntry.SetText(strconv.Itoa(last.DelaySeconds))
+
+	form := widget.NewForm(
+		widget.NewFormItem("Games", countSelect),
+		widget.NewFormItem("Seconds between", delayEntry),
+	)
+	content := container.NewVBox(form, rows)
+	if limit > 0 {
+		content.Add(widget.NewLabel(fmt.Sprintf("%s allows up to %d game(s) at once.", serverDisplayName(), limit)))
+	}
+
+	d := dialog.NewCustomConfirm("Multi-Box Launch", "Launch", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		seconds, err := strconv.Atoi(delayEntry.Text)
+		if err != nil || seconds <= 0 {
+			seconds = defaultBoxDelay
+		}
+
+		settings := &MultiboxConfig{Instances: append([]string{}, choices...), DelaySeconds: seconds}
+		rootConfig.Multibox = settings
+		config.Multibox = settings
+		saveConfig(rootConfig)
+
+		go launchMultibox(win, settings)
+	}, win)
+	d.Resize(fyne.NewSize(420, 0))
+	d.Show()
+}
+
+// launchMultibox starts the configured games, waiting between each start.
+// LaunchPad stays open to keep track of them.
+func launchMultibox(win fyne.Window, settings *MultiboxConfig) {
+	playButton.Disable()
+	setBusy(true)
+	defer setBusy(false)
+	defer playButton.Enable()
+
+	if err := checkBoxLimit(len(settings.Instances)); err != nil {
+		showError(win, err.Error())
+		showRunningStatus()
+		return
+	}
+	if !confirmLaunchIfOffline(win) {
+		showRunningStatus()
+		return
+	}
+
+	total := len(settings.Instances)
+	for i, name := range settings.Instances {
+		if i > 0 {
+			statusLabel.SetText(fmt.Sprintf("🎮 Starting game %d of %d in %d seconds...", i+1, total, settings.DelaySeconds))
+			time.Sleep(time.Duration(settings.DelaySeconds) * time.Second)
+		}
+		statusLabel.SetText(fmt.Sprintf("🎮 Starting game %d of %d...", i+1, total))
+
+		err := startInstance(win, findLaunchProfile(config.LaunchProfiles, name))
+		if err != nil {
+			showError(win, fmt.Sprintf("Failed to start game %d of %d: %v", i+1, total, err))
+			break
+		}
+	}
+	showRunningStatus()
+}
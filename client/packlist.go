@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// packList is the body of the Content Packs dialog: a checkbox for each
+// pack and the total size of the ones ticked
+type packList struct {
+	content *fyne.Container
+	checks  []*widget.Check
+	total   *widget.Label
+}
+
+// newPackList shows packs ticked as they are now. Every checkbox is created
+// and set before any gets its OnChanged, because SetChecked calls OnChanged
+// and the total reads them all.
+func newPackList(packs []ContentPack, choices map[string]bool) *packList {
+	l := &packList{checks: make([]*widget.Check, len(packs)), total: widget.NewLabel("")}
+
+	list := container.NewVBox()
+	for i, pack := range packs {
+		l.checks[i] = widget.NewCheck(fmt.Sprintf("%s (%s, %d files)", pack.Name, formatBytes(pack.Size), pack.Files), nil)
+		l.checks[i].SetChecked(packEnabled(pack, choices))
+		list.Add(l.checks[i])
+		if pack.Description != "" {
+			description := widget.NewLabel(pack.Description)
+			description.Wrapping = fyne.TextWrapWord
+			list.Add(description)
+		}
+	}
+
+	updateTotal := func(bool) {
+		var size int64
+		for i, pack := range packs {
+			if l.checks[i].Checked {
+				size += pack.Size
+			}
+		}
+		l.total.SetText(fmt.Sprintf("Selected: %s", formatBytes(size)))
+	}
+	for _, check := range l.checks {
+		check.OnChanged = updateTotal
+	}
+	updateTotal(true)
+
+	l.content = container.NewBorder(nil, l.total, nil, nil, container.NewVScroll(list))
+	return l
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"fyne.io/fyne/v2/test"
+)
+
+func TestNewPackList(t *testing.T) {
+	test.NewApp()
+	packs := []ContentPack{
+		{Name: "Textures", Size: 3000, Files: 10, Default: true},
+		{Name: "Music", Size: 500, Files: 2},
+		{Name: "Voices", Size: 200, Files: 4},
+	}
+	tests := []struct {
+		name    string
+		choices map[string]bool
+		want    []bool
+		total   string
+	}{
+		{"defaults, first enabled", map[string]bool{}, []bool{true, false, false}, "Selected: " + formatBytes(3000)},
+		{"middle enabled", map[string]bool{"Textures": false, "Music": true}, []bool{false, true, false}, "Selected: " + formatBytes(500)},
+		{"all enabled", map[string]bool{"Music": true, "Voices": true}, []bool{true, true, true}, "Selected: " + formatBytes(3700)},
+		{"none enabled", map[string]bool{"Textures": false}, []bool{false, false, false}, "Selected: " + formatBytes(0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newPackList(packs, tt.choices)
+			for i, want := range tt.want {
+				if got := l.checks[i].Checked; got != want {
+					t.Errorf("%s checked = %v, want %v", packs[i].Name, got, want)
+				}
+			}
+			if l.total.Text != tt.total {
+				t.Errorf("total = %q, want %q", l.total.Text, tt.total)
+			}
+
+			// Ticking a box updates the total
+			l.checks[2].SetChecked(!l.checks[2].Checked)
+			if l.total.Text == tt.total {
+				t.Errorf("total not updated after ticking %s", packs[2].Name)
+			}
+		})
+	}
+}
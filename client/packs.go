@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Content packs are optional groups of files, such as high-res textures or
+// custom music, that players opt into. The manifest lists the packs and tags
+// each of their files; files of packs the player hasn't enabled are dropped
+// from the manifest as soon as it is read, so they are neither downloaded
+// nor verified, and the usual obsolete file handling removes them if they
+// were installed before.
+
+// The player's pack choices, kept in the game folder next to the local manifest
+const packChoicesFile = ".patcher-packs.json"
+
+// ContentPack is one entry in the manifest's "packs" list
+type ContentPack struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Size        int64  `json:"size"`              // total size of the pack's files in bytes
+	Files       int    `json:"files"`             // number of files in the pack
+	Default     bool   `json:"default,omitempty"` // enabled unless the player turns it off
+}
+
+// loadPackChoices returns the packs the player turned on (true) or off
+// (false). Packs missing from it use their default.
+func loadPackChoices() map[string]bool {
+	choices := map[string]bool{}
+	data, err := os.ReadFile(packChoicesFile)
+	if err != nil {
+		return choices
+	}
+	json.Unmarshal(data, &choices)
+	return choices
+}
+
+// savePackChoices saves the player's pack choices
+func savePackChoices(choices map[string]bool) error {
+	data, err := json.MarshalIndent(choices, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(packChoicesFile, data)
+}
+
+// packEnabled reports whether pack is turned on
+func packEnabled(pack ContentPack, choices map[string]bool) bool {
+	if on, ok := choices[pack.Name]; ok {
+		return on
+	}
+	return pack.Default
+}
+
+// applyPackSelection drops the files of disabled packs from manifest. Files
+// naming a pack the manifest doesn't list are kept.
+func applyPackSelection(manifest *Manifest) {
+	if len(manifest.Packs) == 0 {
+		return
+	}
+
+	choices := loadPackChoices()
+	disabled := map[string]bool{}
+	for _, pack := range manifest.Packs {
+		if !packEnabled(pack, choices) {
+			disabled[pack.Name] = true
+		}
+	}
+	if len(disabled) == 0 {
+		return
+	}
+
+	files := make([]FileEntry, 0, len(manifest.Files))
+	for _, file := range manifest.Files {
+		if !disabled[file.Pack] {
+			files = append(files, file)
+		}
+	}
+	manifest.Files = files
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+// showPacksDialog lists the server's content packs with a checkbox each.
+// Changes are applied by a normal update check, which downloads newly
+// enabled packs and removes disabled ones.
+func showPacksDialog(win fyne.Window) {
+	manifest, err := downloadManifest(config.ServerURL)
+	if err != nil {
+		manifest, _, err = lastKnownManifest(config.ServerURL)
+	}
+	if err != nil {
+		showError(win, fmt.Sprintf("Could not load the list of content packs:\n\n%v", err))
+		return
+	}
+	if len(manifest.Packs) == 0 {
+		dialog.ShowInformation("Content Packs", fmt.Sprintf("%s doesn't offer any optional content.", serverDisplayName()), win)
+		return
+	}
+
+	choices := loadPackChoices()
+	packs := newPackList(manifest.Packs, choices)
+	d := dialog.NewCustomConfirm("Content Packs", "Apply", "Cancel", packs.content, func(ok bool) {
+		if !ok {
+			return
+		}
+		changed := false
+		for i, pack := range manifest.Packs {
+			on := packs.checks[i].Checked
+			if on != packEnabled(pack, choices) {
+				changed = true
+				slog.Info("content pack changed", "pack", pack.Name, "enabled", on)
+			}
+			choices[pack.Name] = on
+		}
+		if !changed {
+			return
+		}
+		if err := savePackChoices(choices); err != nil {
+			showError(win, fmt.Sprintf("Could not save your content pack choices:\n\n%v", err))
+			return
+		}
+		go startUpdateCheck(win)
+	}, win)
+	d.Resize(fyne.NewSize(460, 380))
+	d.Show()
+}
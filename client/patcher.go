@@ -3,11 +3,12 @@ package main
 import (
 	"crypto/md5"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"net/http"
+	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -18,123 +19,483 @@ type FileEntry struct {
 	Path string `json:"path"`
 	MD5  string `json:"md5"`
 	Size int64  `json:"size"`
+	Pack string `json:"pack,omitempty"` // content pack the file belongs to, see packs.go
 }
 
 type Manifest struct {
-	Version string      `json:"version"`
-	Files   []FileEntry `json:"files"`
-}
+	Version  string        `json:"version"`
+	Files    []FileEntry   `json:"files"`
+	Launcher []FileEntry   `json:"launcher,omitempty"` // updated by LaunchPad, not here
+	Packs    []ContentPack `json:"packs,omitempty"`    // packs are chosen in LaunchPad
 
-type Config struct {
-	ServerURL    string `json:"server_url"`
-	GameExe      string `json:"game_exe"`
-	GameArgs     string `json:"game_args"`
+	Installed map[string]installedFile `json:"installed,omitempty"` // local manifest only, see saveLocalManifest
 }
 
 const (
 	configFile = "patcher-config.json"
 )
 
+// Exit codes, so scripts can tell what happened
+const (
+	exitUpToDate = 0 // nothing needed changing
+	exitError    = 1 // config, disk or launch failure
+	exitUsage    = 2 // bad command line
+	exitNetwork  = 3 // patch server unreachable or a download failed
+	exitUpdated  = 4 // files were downloaded or removed
+	exitDrift    = 5 // verify/status found files that differ from the server
+	exitPending  = 6 // verify/status found an interrupted update; update or repair finishes it
+)
+
+const usageText = `Usage: patcher [flags] [command]
+
+Commands:
+  update    Patch the game, then launch it (default)
+  verify    Rehash every file and report problems without changing anything
+  repair    Rehash every file and fix anything missing, corrupt or obsolete
+  status    Quick check using the verification cache, changes nothing
+
+Flags:
+  --dir DIR       Game folder (default: current folder)
+  --config FILE   Config file (default: patcher-config.json in the game folder)
+  --profile NAME  Server profile from the config (default: the one last
+                  selected in LaunchPad); its game_dir is used unless --dir
+                  is given
+  --launch NAME   Launch profile from the config (default: the one last
+                  selected in LaunchPad)
+  --no-launch     Don't start the game after "update"
+  --json          Print a JSON report instead of progress messages
+
+Exit codes:
+  0  up to date
+  1  error (config, disk or launch failure)
+  2  bad command line
+  3  network failure
+  4  files were updated
+  5  files differ from the server (verify, status)
+`
+
+// cliReport is printed at the end of a run with --json
+type cliReport struct {
+	Command         string       `json:"command"`
+	Result          string       `json:"result"` // up-to-date, updated, drift, network-error, error
+	ExitCode        int          `json:"exit_code"`
+	Profile         string       `json:"profile,omitempty"`
+	ServerURL       string       `json:"server_url,omitempty"`
+	ManifestVersion string       `json:"manifest_version,omitempty"`
+	LocalVersion    string       `json:"local_version,omitempty"`
+	FilesChecked    int          `json:"files_checked"`
+	Problems        []FileStatus `json:"problems"`
+	Obsolete        []string     `json:"obsolete"`
+	Updated         int          `json:"updated"`
+	Removed         int          `json:"removed"`
+	Error           string       `json:"error,omitempty"`
+}
+
+var (
+	config     *Config // active settings: the config file with the profile applied
+	rootConfig *Config // the config file as stored
+	jsonOutput bool
+)
+
 func main() {
-	fmt.Println("═══════════════════════════════════════")
-	fmt.Println("  Simple EverQuest Patcher v1.0")
-	fmt.Println("═══════════════════════════════════════\n")
+	// Run without arguments (e.g. double-clicked) keeps the original
+	// behaviour: patch, launch, and pause on errors so they can be read
+	interactive := len(os.Args) == 1
+
+	code := run(os.Args[1:], interactive)
+	if interactive && code != exitUpToDate && code != exitUpdated {
+		pause()
+	}
+	os.Exit(code)
+}
+
+func run(args []string, interactive bool) int {
+	fs := flag.NewFlagSet("patcher", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usageText) }
+	dir := fs.String("dir", "", "game folder")
+	cfgPath := fs.String("config", configFile, "config file")
+	profileName := fs.String("profile", "", "server profile")
+	launchName := fs.String("launch", "", "launch profile")
+	noLaunch := fs.Bool("no-launch", false, "don't launch the game after updating")
+	fs.BoolVar(&jsonOutput, "json", false, "print a JSON report")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitUpToDate
+		}
+		return exitUsage
+	}
+
+	// Flags may come before or after the command
+	command := "update"
+	if fs.NArg() > 0 {
+		command = fs.Arg(0)
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			if err == flag.ErrHelp {
+				return exitUpToDate
+			}
+			return exitUsage
+		}
+		if fs.NArg() > 0 {
+			fmt.Fprintf(os.Stderr, "Unexpected argument: %s\n\n", fs.Arg(0))
+			fs.Usage()
+			return exitUsage
+		}
+	}
+	switch command {
+	case "update", "verify", "repair", "status":
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", command)
+		fs.Usage()
+		return exitUsage
+	}
+
+	report := &cliReport{Command: command, Problems: []FileStatus{}, Obsolete: []string{}}
+
+	if *dir != "" {
+		if err := os.Chdir(*dir); err != nil {
+			say("✗ Could not open game folder: %v\n", err)
+			return finish(report, exitError, err)
+		}
+	}
+
+	say("═══════════════════════════════════════\n")
+	say("  Simple EverQuest Patcher v1.0\n")
+	say("═══════════════════════════════════════\n\n")
 
 	// Load configuration
-	config, err := loadConfig()
+	var err error
+	rootConfig, err = loadConfig(*cfgPath)
 	if err != nil {
+		if !interactive {
+			say("✗ Error loading config: %v\n", err)
+			return finish(report, exitError, err)
+		}
 		fmt.Printf("Error loading config: %v\n", err)
 		fmt.Println("\nCreating default configuration file...")
-		createDefaultConfig()
-		fmt.Println("Please edit patcher-config.json with your server URL and game settings.")
-		pause()
-		os.Exit(1)
+		createDefaultConfig(*cfgPath)
+		fmt.Printf("Please edit %s with your server URL and game settings.\n", *cfgPath)
+		return exitError
 	}
+	configPath, _ := filepath.Abs(*cfgPath)
 
-	fmt.Printf("Server: %s\n", config.ServerURL)
-	fmt.Printf("Game: %s %s\n\n", config.GameExe, config.GameArgs)
+	// Log next to the config file, not in the game folder
+	if err := setupLogging(filepath.Join(filepath.Dir(configPath), logDirName), "patcher.log", rootConfig.LogLevel); err != nil {
+		say("Warning: %v\n", err)
+	}
+	slog.Info("patcher starting", "command", command, "os", runtime.GOOS, "config", configPath)
+
+	// Pick the server profile and move to its game folder
+	profile := findProfile(rootConfig.Profiles, *profileName)
+	if *profileName == "" {
+		profile = findProfile(rootConfig.Profiles, rootConfig.ActiveProfile)
+		if profile == nil {
+			profile = findProfile(rootConfig.Profiles, "")
+		}
+	} else if profile == nil {
+		err := fmt.Errorf("no server profile called %q in %s", *profileName, *cfgPath)
+		say("✗ %v\n", err)
+		return finish(report, exitUsage, err)
+	}
+	config = applyProfile(rootConfig, profile)
+	if profile != nil {
+		report.Profile = profile.Name
+	}
+	if gameDir := gameDirFor(rootConfig, profile, filepath.Dir(configPath)); *dir == "" && gameDir != "" {
+		if err := os.Chdir(gameDir); err != nil {
+			say("✗ Could not open game folder: %v\n", err)
+			return finish(report, exitError, err)
+		}
+	}
+	report.ServerURL = config.ServerURL
+
+	// Pick how to start the game: --launch, else the last one LaunchPad used
+	launch := findLaunchProfile(config.LaunchProfiles, *launchName)
+	if *launchName == "" {
+		launch = findLaunchProfile(config.LaunchProfiles, config.ActiveLaunchProfile)
+		if launch == nil {
+			launch = findLaunchProfile(config.LaunchProfiles, "")
+		}
+	} else if launch == nil {
+		err := fmt.Errorf("no launch profile called %q in %s", *launchName, *cfgPath)
+		say("✗ %v\n", err)
+		return finish(report, exitUsage, err)
+	}
+
+	// Proxy, CA bundle and pin apply to every request
+	if err := configureHTTP(config.Proxy, config.CABundle, config.SPKIPin); err != nil {
+		say("✗ %v\n", err)
+		return finish(report, exitError, err)
+	}
+
+	// Don't leave credentials typed into the config file in plain text
+	saveCredentials = func() error {
+		if profile != nil && config.Auth != rootConfig.Auth {
+			profile.Auth = config.Auth
+		} else if profile == nil {
+			rootConfig.Auth = config.Auth
+		}
+		return saveConfig(configPath, rootConfig)
+	}
+	sealedRoot := sealCredentials(rootConfig.Auth)
+	sealedProfiles := sealProfileCredentials(rootConfig.Profiles)
+	if sealedRoot || sealedProfiles {
+		saveConfig(configPath, rootConfig)
+	}
+
+	if profile != nil {
+		say("Profile: %s\n", profile.Name)
+	}
+	say("Server: %s\n", config.ServerURL)
+	if launch != nil {
+		args := config.GameArgs
+		if launch.Args != nil {
+			args = strings.Join(launch.Args, " ")
+		}
+		say("Game: %s %s (launch profile %s)\n", config.GameExe, args, launch.Name)
+	} else {
+		say("Game: %s %s\n", config.GameExe, config.GameArgs)
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		say("Folder: %s\n", cwd)
+		if !isGameFolder(cwd, config.GameExe) {
+			say("Warning: %s is not in this folder\n", config.GameExe)
+			for _, found := range detectGameFolders(config.GameExe) {
+				say("  Found it in %s - use --dir or set game_dir\n", found)
+			}
+		}
+	}
+	if config.MaxDownloadKbps > 0 {
+		say("Download limit: %d kbps\n", config.MaxDownloadKbps)
+	}
+	say("\n")
+	downloadLimiter.SetKbps(config.MaxDownloadKbps)
+
+	// Finish or roll back an update that was interrupted last time. verify
+	// and status leave that to update and repair, since it changes files.
+	readOnly := command == "verify" || command == "status"
+	if readOnly && updatePending() {
+		say("✗ An update was interrupted and has not been finished\n")
+		say("  Run \"patcher update\" or \"patcher repair\" to finish or roll it back\n")
+		return finish(report, exitPending, nil)
+	}
+	recovered, err := recoverUpdate()
+	if err != nil {
+		say("✗ Could not recover interrupted update: %v\n", err)
+		return finish(report, exitError, err)
+	}
+	if recovered {
+		say("✓ Recovered an interrupted update\n\n")
+	}
 
 	// Download manifest
-	fmt.Println("Downloading manifest...")
+	say("Downloading manifest...\n")
+	start := time.Now()
 	manifest, err := downloadManifest(config.ServerURL)
 	if err != nil {
-		fmt.Printf("✗ Error downloading manifest: %v\n", err)
-		pause()
-		os.Exit(1)
+		slog.Warn("update check failed", "server", config.ServerURL, "error", err)
+		say("✗ Error downloading manifest: %v\n", err)
+		if errors.Is(err, errAuthRequired) {
+			// Retrying won't help - the config needs credentials
+			say("  Set \"auth\" in %s (see README)\n", *cfgPath)
+			return finish(report, exitError, err)
+		}
+		return finish(report, exitNetwork, err)
+	}
+	report.ManifestVersion = manifest.Version
+	slog.Info("manifest", "server", config.ServerURL, "version", manifest.Version, "files", len(manifest.Files), "duration", time.Since(start))
+	if local := loadLocalManifest(); local != nil {
+		report.LocalVersion = local.Version
 	}
-	fmt.Printf("✓ Manifest loaded (%d files)\n\n", len(manifest.Files))
+	say("✓ Manifest loaded (%d files)\n\n", len(manifest.Files))
 
-	// Check files and build download list
-	fmt.Println("Checking files...")
+	// verify and repair rehash everything; update and status trust the
+	// verification cache for files whose size and mtime haven't changed
+	fullVerify := command == "verify" || command == "repair"
+
+	say("Checking files...\n")
+	var results []FileStatus
+	if readOnly {
+		results = verifyFilesReadOnly(manifest, fullVerify, nil)
+	} else {
+		results = verifyFiles(manifest, fullVerify, nil)
+	}
+	report.FilesChecked = len(results)
 	toDownload := []FileEntry{}
-	for _, file := range manifest.Files {
-		localPath := file.Path
-
-		// Check if file exists
-		info, err := os.Stat(localPath)
-		if os.IsNotExist(err) {
-			fmt.Printf("  [MISSING] %s\n", file.Path)
-			toDownload = append(toDownload, file)
+	for _, result := range results {
+		if result.Status == statusOK {
+			if command == "update" {
+				say("  [OK] %s\n", result.Path)
+			}
 			continue
 		}
+		say("  [%s] %s\n", strings.ToUpper(strings.ReplaceAll(result.Status, "-", " ")), result.Path)
+		report.Problems = append(report.Problems, result)
+		toDownload = append(toDownload, result.File)
+	}
 
-		// Check if size matches
-		if info.Size() != file.Size {
-			fmt.Printf("  [SIZE MISMATCH] %s\n", file.Path)
-			toDownload = append(toDownload, file)
-			continue
+	// Files we installed before that the server no longer has
+	toDelete := findObsoleteFiles(manifest)
+	for _, filePath := range toDelete {
+		say("  [OBSOLETE] %s\n", filePath)
+	}
+	report.Obsolete = append(report.Obsolete, toDelete...)
+
+	// Read-only commands stop here
+	if readOnly {
+		if len(toDownload) > 0 || len(toDelete) > 0 {
+			say("\n✗ %d file(s) differ from the server, %d obsolete\n", len(toDownload), len(toDelete))
+			return finish(report, exitDrift, nil)
 		}
+		say("\n✓ All files are up to date!\n")
+		return finish(report, exitUpToDate, nil)
+	}
 
-		// Check MD5 hash
-		localMD5, err := calculateMD5(localPath)
-		if err != nil || localMD5 != file.MD5 {
-			fmt.Printf("  [HASH MISMATCH] %s\n", file.Path)
-			toDownload = append(toDownload, file)
-			continue
+	code, err := applyUpdates(report, manifest, toDownload, toDelete)
+	if err != nil || command == "repair" || *noLaunch {
+		return finish(report, code, err)
+	}
+
+	// Launch game
+	say("\nLaunching game...\n")
+	err = launchGame(config, launch)
+	if err != nil {
+		say("✗ Error launching game: %v\n", err)
+		return finish(report, exitError, err)
+	}
+
+	say("✓ Game launched successfully!\n")
+	if interactive {
+		time.Sleep(2 * time.Second)
+	}
+	return finish(report, code, nil)
+}
+
+// applyUpdates downloads the given files and removes obsolete ones, keeping
+// the same local state (backups, local manifest) as LaunchPad
+func applyUpdates(report *cliReport, manifest *Manifest, toDownload []FileEntry, toDelete []string) (int, error) {
+	start := time.Now()
+	slog.Info("update started", "version", manifest.Version, "download", len(toDownload), "delete", len(toDelete))
+
+	// Make sure the update fits before downloading anything
+	if err := checkDiskSpace(toDownload); err != nil {
+		say("\n✗ %v\n", err)
+		return exitError, err
+	}
+
+	// Originals that don't fit in the backup store can't be restored later
+	if unbacked := unbackedOriginals(toDownload); len(unbacked) > 0 {
+		slog.Warn("backup store is full", "files", unbacked, "cap_mb", maxBackupBytes()/1024/1024)
+		say("\n⚠ The backup folder would go over its %d MB limit (max_backup_mb); %d original file(s) will be overwritten without a backup:\n", maxBackupBytes()/1024/1024, len(unbacked))
+		for _, path := range unbacked {
+			say("  %s\n", path)
+		}
+	}
+
+	gameFolder, _ := os.Getwd()
+	changes := len(toDownload) + len(toDelete)
+	if changes > 0 {
+		if err := runHooks(hookPrePatch, config, gameFolder); err != nil {
+			say("\n✗ %v\n", err)
+			return exitError, err
 		}
+	}
 
-		// File is OK
-		fmt.Printf("  [OK] %s\n", file.Path)
+	txn, err := beginUpdate(manifest)
+	if err != nil {
+		say("\n✗ %v\n", err)
+		return exitError, err
 	}
 
-	// Download files if needed
 	if len(toDownload) > 0 {
-		fmt.Printf("\n%d file(s) need updating\n", len(toDownload))
-		fmt.Println("\nDownloading files...")
+		say("\n%d file(s) need updating\n", len(toDownload))
+		say("\nDownloading files...\n")
 
 		for i, file := range toDownload {
-			fmt.Printf("[%d/%d] %s...", i+1, len(toDownload), file.Path)
+			say("[%d/%d] %s...", i+1, len(toDownload), file.Path)
 
-			err := downloadFile(config.ServerURL, file.Path)
+			err := txn.stage(file)
 			if err != nil {
-				fmt.Printf(" ✗ FAILED: %v\n", err)
-				pause()
-				os.Exit(1)
+				slog.Error("download failed", "path", file.Path, "error", err)
+				txn.abort()
+				say(" ✗ FAILED: %v\n", err)
+				return exitNetwork, fmt.Errorf("failed to download %s: %v", file.Path, err)
 			}
 
-			fmt.Println(" ✓")
+			say(" ✓\n")
 		}
+	}
 
-		fmt.Println("\n✓ All files updated!")
-	} else {
-		fmt.Println("\n✓ All files are up to date!")
+	// Swap everything into place and remove obsolete files in one go
+	err = txn.commit(toDelete)
+	if err != nil {
+		slog.Error("update failed", "version", manifest.Version, "error", err)
+		if errors.Is(err, errRollbackFailed) {
+			say("\n✗ Update could not be installed and some files could not be put back: %v\n", err)
+			say("  Close the game if it is running and run the patcher again to finish undoing the update\n")
+		} else {
+			say("\n✗ Update could not be installed, no files were changed: %v\n", err)
+		}
+		return exitError, err
+	}
+	report.Updated = len(toDownload)
+	report.Removed = len(toDelete)
+	slog.Info("update installed", "version", manifest.Version, "duration", time.Since(start))
+
+	if changes > 0 {
+		if err := runHooks(hookPostPatch, config, gameFolder); err != nil {
+			say("\n✗ %v\n", err)
+			return exitError, err
+		}
 	}
 
-	// Launch game
-	fmt.Println("\nLaunching game...")
-	err = launchGame(config)
+	if report.Updated > 0 || report.Removed > 0 {
+		say("\n✓ All files updated!\n")
+		return exitUpdated, nil
+	}
+	say("\n✓ All files are up to date!\n")
+	return exitUpToDate, nil
+}
+
+// finish records the outcome, prints the JSON report if requested and
+// returns the exit code
+func finish(report *cliReport, code int, err error) int {
+	report.ExitCode = code
+	switch code {
+	case exitUpToDate:
+		report.Result = "up-to-date"
+	case exitUpdated:
+		report.Result = "updated"
+	case exitDrift:
+		report.Result = "drift"
+	case exitPending:
+		report.Result = "update-pending"
+	case exitNetwork:
+		report.Result = "network-error"
+	default:
+		report.Result = "error"
+	}
 	if err != nil {
-		fmt.Printf("✗ Error launching game: %v\n", err)
-		pause()
-		os.Exit(1)
+		report.Error = err.Error()
 	}
+	slog.Info("finished", "command", report.Command, "result", report.Result, "exit_code", code, "error", report.Error)
 
-	fmt.Println("✓ Game launched successfully!")
-	time.Sleep(2 * time.Second)
+	if jsonOutput {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	}
+	return code
 }
 
-func loadConfig() (*Config, error) {
-	data, err := os.ReadFile(configFile)
+// say prints a progress message unless --json was given
+func say(format string, args ...interface{}) {
+	if !jsonOutput {
+		fmt.Printf(format, args...)
+	}
+}
+
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -148,7 +509,13 @@ func loadConfig() (*Config, error) {
 	return &config, nil
 }
 
-func createDefaultConfig() {
+// saveConfig writes config to path, keeping any settings this version
+// doesn't know about
+func saveConfig(path string, config *Config) error {
+	return writeConfigFile(path, config)
+}
+
+func createDefaultConfig(path string) {
 	config := Config{
 		ServerURL: "http://example.com/patches",
 		GameExe:   "eqgame.exe",
@@ -156,38 +523,43 @@ func createDefaultConfig() {
 	}
 
 	data, _ := json.MarshalIndent(config, "", "  ")
-	os.WriteFile(configFile, data, 0644)
-	fmt.Printf("Created %s\n", configFile)
+	os.WriteFile(path, data, 0644)
+	fmt.Printf("Created %s\n", path)
 }
 
+// downloadManifest fetches manifest.json, reusing the cached copy if the
+// server says it hasn't changed
 func downloadManifest(serverURL string) (*Manifest, error) {
 	url := strings.TrimRight(serverURL, "/") + "/manifest.json"
 
-	resp, err := http.Get(url)
+	data, err := cachedGet(url)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
-	}
 
 	var manifest Manifest
-	err = json.NewDecoder(resp.Body).Decode(&manifest)
+	err = json.Unmarshal(data, &manifest)
 	if err != nil {
 		return nil, err
 	}
+	applyPackSelection(&manifest)
 
 	return &manifest, nil
 }
 
-func downloadFile(serverURL, filePath string) error {
+// downloadFileTo downloads the manifest file filePath from the server and
+// saves it as destPath
+func downloadFileTo(serverURL, filePath, destPath string) error {
+	// Never write outside the game folder, whatever the manifest says
+	if err := checkManifestPath(filePath); err != nil {
+		return err
+	}
+
 	// Construct URL
 	url := strings.TrimRight(serverURL, "/") + "/" + filePath
 
 	// Download file
-	resp, err := http.Get(url)
+	resp, err := httpGet(url)
 	if err != nil {
 		return err
 	}
@@ -198,7 +570,7 @@ func downloadFile(serverURL, filePath string) error {
 	}
 
 	// Create directory if needed
-	dir := filepath.Dir(filePath)
+	dir := filepath.Dir(destPath)
 	if dir != "." {
 		err = os.MkdirAll(dir, 0755)
 		if err != nil {
@@ -207,14 +579,14 @@ func downloadFile(serverURL, filePath string) error {
 	}
 
 	// Create temporary file
-	tmpFile := filePath + ".tmp"
+	tmpFile := destPath + ".tmp"
 	out, err := os.Create(tmpFile)
 	if err != nil {
 		return err
 	}
 
-	// Copy data
-	_, err = io.Copy(out, resp.Body)
+	// Copy data (paced by max_download_kbps)
+	_, err = io.Copy(out, newThrottledReader(resp.Body))
 	out.Close()
 	if err != nil {
 		os.Remove(tmpFile)
@@ -222,7 +594,7 @@ func downloadFile(serverURL, filePath string) error {
 	}
 
 	// Rename to final name
-	err = os.Rename(tmpFile, filePath)
+	err = os.Rename(tmpFile, destPath)
 	if err != nil {
 		os.Remove(tmpFile)
 		return err
@@ -246,19 +618,34 @@ func calculateMD5(filePath string) (string, error) {
 	return fmt.Sprintf("%x", hash.Sum(nil)), nil
 }
 
-func launchGame(config *Config) error {
-	args := []string{}
-	if config.GameArgs != "" {
-		args = strings.Fields(config.GameArgs)
+// launchGame starts the game from the current folder, the way launch
+// profile launch says if it isn't nil
+func launchGame(config *Config, launch *LaunchProfile) error {
+	gameFolder, err := os.Getwd()
+	if err != nil {
+		return err
 	}
+	cmd, err := gameCommand(config, gameFolder, launch)
+	if err != nil {
+		return err
+	}
+	restore, err := prepareLaunch(config, gameFolder, launch)
+	if err != nil {
+		return err
+	}
+	slog.Info("launching game", "exe", cmd.Path, "args", cmd.Args[1:], "dir", cmd.Dir)
 
-	cmd := exec.Command(config.GameExe, args...)
-
-	// On Windows, don't wait for the game to exit
+	// On Windows, don't wait for the game to exit; the next launch puts
+	// back the eqclient.ini settings the launch profile replaced
 	if runtime.GOOS == "windows" {
-		return cmd.Start()
+		err := cmd.Start()
+		if err != nil {
+			restore()
+		}
+		return err
 	}
 
+	defer restore()
 	return cmd.Run()
 }
 
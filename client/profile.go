@@ -0,0 +1,141 @@
+package main
+
+import (
+	"path/filepath"
+)
+
+// ServerProfile is one patch server in the "profiles" list of
+// patcher-config.json, e.g. a live and a test server sharing one launcher.
+// Empty fields fall back to the top-level settings.
+type ServerProfile struct {
+	Name          string      `json:"name"`
+	ServerURL     string      `json:"server_url"`
+	ServerName    string      `json:"server_name,omitempty"`
+	LauncherTitle string      `json:"launcher_title,omitempty"`
+	WebsiteURL    string      `json:"website_url,omitempty"`
+	WebsiteLabel  string      `json:"website_label,omitempty"`
+	GameDir       string      `json:"game_dir,omitempty"` // absolute, or relative to the config file
+	GameExe       string      `json:"game_exe,omitempty"`
+	GameArgs      string      `json:"game_args,omitempty"`
+	Auth          *AuthConfig `json:"auth,omitempty"`
+
+	LoginServer    string   `json:"login_server,omitempty"` // host[:port], for eqhost.txt and LaunchPad's status line
+	LoginCheckPort int      `json:"login_check_port,omitempty"`
+	StatusURL      string   `json:"status_url,omitempty"`
+	EQHost         []string `json:"eqhost,omitempty"`
+}
+
+// findProfile returns the profile called name, or the first profile if name
+// is empty. Returns nil if there are no profiles or name isn't one of them.
+func findProfile(profiles []ServerProfile, name string) *ServerProfile {
+	if len(profiles) == 0 {
+		return nil
+	}
+	if name == "" {
+		return &profiles[0]
+	}
+	for i := range profiles {
+		if profiles[i].Name == name {
+			return &profiles[i]
+		}
+	}
+	return nil
+}
+
+// applyProfile returns the settings to use with profile p: the top-level
+// settings with the profile's non-empty fields on top. A profile on another
+// server_url never inherits the login server, eqhost or status settings.
+func applyProfile(root *Config, p *ServerProfile) *Config {
+	active := *root
+	if p == nil {
+		return &active
+	}
+
+	if p.ServerURL != "" {
+		active.ServerURL = p.ServerURL
+	}
+	if p.ServerName != "" {
+		active.ServerName = p.ServerName
+	} else if p.Name != "" {
+		active.ServerName = p.Name
+	}
+	if p.LauncherTitle != "" {
+		active.LauncherTitle = p.LauncherTitle
+	}
+	if p.WebsiteURL != "" {
+		active.WebsiteURL = p.WebsiteURL
+		active.WebsiteLabel = p.WebsiteLabel
+	}
+	if p.GameDir != "" {
+		active.GameDir = p.GameDir
+	}
+	if p.GameExe != "" {
+		active.GameExe = p.GameExe
+	}
+	if p.GameArgs != "" {
+		active.GameArgs = p.GameArgs
+	}
+	switch {
+	case p.ServerURL != "" && p.ServerURL != root.ServerURL:
+		// Another server: the root's login server and status belong to the
+		// first one, so only the profile's own are used
+		active.LoginServer = p.LoginServer
+		active.LoginCheckPort = p.LoginCheckPort
+		active.StatusURL = p.StatusURL
+		active.EQHost = p.EQHost
+	default:
+		if p.LoginServer != "" {
+			active.LoginServer = p.LoginServer
+			active.LoginCheckPort = p.LoginCheckPort
+			active.EQHost = p.EQHost // the root's eqhost names another server
+		}
+		if len(p.EQHost) > 0 {
+			active.EQHost = p.EQHost
+		}
+		if p.StatusURL != "" {
+			active.StatusURL = p.StatusURL
+		}
+	}
+	if p.Auth != nil {
+		active.Auth = p.Auth
+	}
+	return &active
+}
+
+// gameDirFor is the game_dir of profile p (nil for none), else the
+// top-level one, made absolute against configDir, the folder holding the
+// config file. LaunchPad and the patcher both resolve it this way. Returns
+// "" if neither is set.
+func gameDirFor(root *Config, p *ServerProfile, configDir string) string {
+	return resolveDir(applyProfile(root, p).GameDir, configDir)
+}
+
+// profileNames lists the profiles in config file order
+func profileNames(profiles []ServerProfile) []string {
+	names := make([]string, 0, len(profiles))
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// resolveDir makes a directory from the config file absolute, relative to
+// base (the folder holding the config file). Empty stays empty.
+func resolveDir(dir, base string) string {
+	if dir == "" || filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(base, dir)
+}
+
+// sealProfileCredentials seals the credentials of every profile. Returns
+// true if anything changed.
+func sealProfileCredentials(profiles []ServerProfile) bool {
+	changed := false
+	for i := range profiles {
+		if sealCredentials(profiles[i].Auth) {
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyProfileServerSettings(t *testing.T) {
+	root := &Config{
+		ServerURL:      "http://patch.example.com/live",
+		LoginServer:    "login.example.com",
+		LoginCheckPort: 9000,
+		StatusURL:      "status.json",
+		EQHost:         []string{"login.example.com:5998"},
+	}
+	tests := []struct {
+		name    string
+		profile *ServerProfile
+		want    Config
+	}{
+		{
+			name:    "no profile",
+			profile: nil,
+			want:    *root,
+		},
+		{
+			name:    "same server inherits",
+			profile: &ServerProfile{Name: "Live", ServerURL: root.ServerURL},
+			want:    *root,
+		},
+		{
+			name:    "same server, own login server",
+			profile: &ServerProfile{Name: "Live", LoginServer: "login2.example.com"},
+			want: Config{
+				ServerURL:   root.ServerURL,
+				LoginServer: "login2.example.com",
+				StatusURL:   "status.json",
+			},
+		},
+		{
+			name:    "other server inherits nothing",
+			profile: &ServerProfile{Name: "Test", ServerURL: "http://patch.example.com/test"},
+			want:    Config{ServerURL: "http://patch.example.com/test"},
+		},
+		{
+			name: "other server with its own settings",
+			profile: &ServerProfile{Name: "Test", ServerURL: "http://patch.example.com/test",
+				LoginServer: "test.example.com:5999", StatusURL: "test-status.json"},
+			want: Config{ServerURL: "http://patch.example.com/test", LoginServer: "test.example.com:5999", StatusURL: "test-status.json"},
+		},
+	}
+	for _, tt := range tests {
+		got := applyProfile(root, tt.profile)
+		gotFields := []interface{}{got.ServerURL, got.LoginServer, got.LoginCheckPort, got.StatusURL, got.EQHost}
+		wantFields := []interface{}{tt.want.ServerURL, tt.want.LoginServer, tt.want.LoginCheckPort, tt.want.StatusURL, tt.want.EQHost}
+		if !reflect.DeepEqual(gotFields, wantFields) {
+			t.Errorf("%s: got %v, want %v", tt.name, gotFields, wantFields)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+// activeProfileName is the name of the profile in use, or "" if the config
+// has no profiles
+func activeProfileName() string {
+	if p := findProfile(rootConfig.Profiles, rootConfig.ActiveProfile); p != nil {
+		return p.Name
+	}
+	return ""
+}
+
+// profileGameFolder is where the named profile's game files live: its own
+// game_dir, else the top-level game_dir, else the launcher folder
+func profileGameFolder(name string) string {
+	if dir := gameDirFor(rootConfig, findProfile(rootConfig.Profiles, name), launcherDir); dir != "" {
+		return dir
+	}
+	return launcherDir
+}
+
+// activateProfile switches config to the named profile and moves the working
+// directory to its game folder, where all patching happens. Nothing changes
+// if it fails.
+func activateProfile(name string) error {
+	p := findProfile(rootConfig.Profiles, name)
+	if p == nil && len(rootConfig.Profiles) > 0 {
+		return fmt.Errorf("there is no server profile called %q", name)
+	}
+
+	dir := profileGameFolder(name)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("game folder not found: %s", dir)
+	}
+	if err := os.Chdir(dir); err != nil {
+		return fmt.Errorf("could not open game folder %s: %v", dir, err)
+	}
+
+	config = applyProfile(rootConfig, p)
+	gameFolder = dir
+	return nil
+}
+
+// saveActiveConfig writes the config file, putting changes made to the
+// active settings (download limit, credentials) back where they came from
+func saveActiveConfig() error {
+	rootConfig.MaxDownloadKbps = config.MaxDownloadKbps
+
+	p := findProfile(rootConfig.Profiles, rootConfig.ActiveProfile)
+	if p != nil && config.Auth != rootConfig.Auth {
+		p.Auth = config.Auth
+	} else if p == nil {
+		rootConfig.Auth = config.Auth
+	}
+
+	return saveConfig(rootConfig)
+}
+
+// createProfileSelect builds the server selector shown when the config has
+// more than one profile
+func createProfileSelect(win fyne.Window) *widget.Select {
+	profileSelect := widget.NewSelect(profileNames(rootConfig.Profiles), nil)
+	profileSelect.SetSelected(activeProfileName())
+	profileSelect.OnChanged = func(name string) {
+		if name != activeProfileName() {
+			go switchProfile(win, name)
+		}
+	}
+	return profileSelect
+}
+
+// switchProfile makes name the active profile, reloads the branding, theme
+// and news for its server, restarts the status checks and checks its game
+// folder for updates
+func switchProfile(win fyne.Window, name string) {
+	previous := activeProfileName()
+
+	err := activateProfile(name)
+	if err != nil {
+		showError(win, fmt.Sprintf("Could not switch to %s:\n\n%v", name, err))
+		profileSelect.SetSelected(previous)
+		return
+	}
+
+	rootConfig.ActiveProfile = name
+	saveConfig(rootConfig)
+
+	applyBranding(win)
+	loadTheme(config.ServerURL)
+	loadNews(config.ServerURL)
+	startStatusChecks()
+
+	startUpdateCheck(win)
+}
+
+// applyBranding shows the active profile's title, server name and website
+func applyBranding(win fyne.Window) {
+	title := config.LauncherTitle
+	if title == "" {
+		title = "EverQuest LaunchPad"
+	}
+	win.SetTitle(title)
+
+	serverLabel.Text = config.ServerName
+	serverLabel.Refresh()
+
+	if config.WebsiteURL == "" {
+		websiteButton.Hide()
+		websiteSpacer.Hide()
+		return
+	}
+	label := config.WebsiteLabel
+	if label == "" {
+		label = "Visit Website"
+	}
+	websiteButton.SetText(label)
+	websiteButton.Show()
+	websiteSpacer.Show()
+}
+
+// createLaunchSelect builds the launch profile selector shown next to PLAY
+// when the config has more than one launch profile
+func createLaunchSelect() *widget.Select {
+	launchSelect := widget.NewSelect(launchProfileNames(rootConfig.LaunchProfiles), nil)
+	if p := findLaunchProfile(rootConfig.LaunchProfiles, rootConfig.ActiveLaunchProfile); p != nil {
+		launchSelect.SetSelected(p.Name)
+	}
+	launchSelect.OnChanged = func(name string) {
+		rootConfig.ActiveLaunchProfile = name
+		config.ActiveLaunchProfile = name
+		saveConfig(rootConfig)
+	}
+	return launchSelect
+}
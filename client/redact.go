@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Config keys whose values are replaced before the config goes in a bundle
+var secretConfigKeys = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"refresh_token": true,
+}
+
+// redactedConfig returns the config file with passwords, tokens and the
+// user info of URLs replaced. It works on the raw JSON so nothing else is lost.
+func redactedConfig(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s is not valid JSON: %v", filepath.Base(path), err)
+	}
+	return json.MarshalIndent(redactSecrets(raw), "", "  ")
+}
+
+// redactSecrets replaces secret values and the user info of every URL in a
+// decoded config file
+func redactSecrets(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if s, ok := item.(string); ok && s != "" && secretConfigKeys[key] {
+				v[key] = "REDACTED"
+			} else if ok && (key == "proxy" || strings.HasSuffix(key, "_url")) {
+				v[key] = redactURLUser(s)
+			} else {
+				v[key] = redactSecrets(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactSecrets(item)
+		}
+	}
+	return value
+}
+
+// redactURLUser replaces the user name and password in a URL. A URL
+// without a scheme is read the way newTransport reads the proxy setting.
+func redactURLUser(raw string) string {
+	addedScheme := !strings.Contains(raw, "://")
+	if addedScheme {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		// Can't tell what's in it, so hide anything that looks like user info
+		redacted := redactText(raw)
+		if addedScheme {
+			redacted = strings.TrimPrefix(redacted, "http://")
+		}
+		return redacted
+	}
+	if u.User != nil {
+		u.User = url.User("REDACTED")
+	}
+	redacted := u.String()
+	if addedScheme {
+		redacted = strings.TrimPrefix(redacted, "http://")
+	}
+	return redacted
+}
+
+// urlUserInfo matches the user info of URLs inside any text, e.g. an error
+// message quoting a proxy URL
+var urlUserInfo = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://)[^\s/@"]+@`)
+
+// redactText replaces the user info of every URL in s
+func redactText(s string) string {
+	return urlUserInfo.ReplaceAllString(s, "${1}REDACTED@")
+}
+
+// redactLogAttr keeps URL credentials out of the log files
+func redactLogAttr(groups []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(redactText(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(redactText(err.Error()))
+		}
+	}
+	return a
+}
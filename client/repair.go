@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// startRepair rehashes every manifest file, ignoring the verification cache,
+// and shows what's wrong before fixing anything
+func startRepair(win fyne.Window) {
+	playButton.Disable()
+	setBusy(true)
+	progressBar.Show()
+	progressBar.SetValue(0)
+	statusLabel.SetText("Connecting to patch server...")
+
+	// Finish or roll back an interrupted update before checking the files
+	if _, err := recoverUpdate(); err != nil {
+		statusLabel.SetText("⚠️ Repair failed")
+		progressBar.Hide()
+		showError(win, fmt.Sprintf("A previous update could not be finished or undone:\n\n%v\n\nClose the game if it is running and try again.", err))
+		playButton.Enable()
+		setBusy(false)
+		return
+	}
+
+	manifest, err := downloadManifest(config.ServerURL)
+	if errors.Is(err, errAuthRequired) {
+		promptSignIn(win, func() { startRepair(win) }, func() {
+			statusLabel.SetText("⚠️ Not signed in - Ready to play")
+			playButton.Enable()
+			setBusy(false)
+		})
+		return
+	}
+	if err != nil {
+		statusLabel.SetText("⚠️ Repair failed - patch server unavailable")
+		progressBar.Hide()
+		showError(win, fmt.Sprintf("Could not download the manifest:\n\n%v", err))
+		playButton.Enable()
+		setBusy(false)
+		return
+	}
+
+	statusLabel.SetText(fmt.Sprintf("Verifying %d files...", len(manifest.Files)))
+	results := verifyFiles(manifest, true, func(done, total int) {
+		progressBar.SetValue(float64(done) / float64(total))
+	})
+	report := buildRepairReport(manifest, results)
+	progressBar.Hide()
+
+	if report.count() == 0 {
+		saveLocalManifest(manifest, manifestPaths(manifest))
+		statusLabel.SetText("✓ All files verified - Ready to play")
+		dialog.ShowInformation("Verify & Repair", fmt.Sprintf("All %d files verified - no problems found.", len(results)), win)
+		playButton.Enable()
+		setBusy(false)
+		return
+	}
+
+	statusLabel.SetText(fmt.Sprintf("⚠️ %d problem(s) found", report.count()))
+	showRepairReport(win, manifest, report)
+}
+
+// showRepairReport lists the problems by category and lets the player pick
+// which categories to fix
+func showRepairReport(win fyne.Window, manifest *Manifest, report *repairReport) {
+	checks := make(map[string]*widget.Check)
+	accordion := widget.NewAccordion()
+
+	for _, cat := range repairCategories {
+		items := report.Items[cat.Name]
+		if len(items) == 0 {
+			continue
+		}
+
+		check := widget.NewCheck(fmt.Sprintf("%s: %d file(s), %s - %s", cat.Name, len(items), formatBytes(report.size(cat.Name)), cat.Description), nil)
+		check.SetChecked(cat.Default)
+		checks[cat.Name] = check
+
+		list := widget.NewList(
+			func() int { return len(items) },
+			func() fyne.CanvasObject { return widget.NewLabel("") },
+			func(id widget.ListItemID, obj fyne.CanvasObject) {
+				obj.(*widget.Label).SetText(fmt.Sprintf("%s  (%s)", items[id].Path, formatBytes(items[id].Size)))
+			},
+		)
+		listHolder := container.NewGridWrap(fyne.NewSize(520, 150), list)
+		accordion.Append(widget.NewAccordionItem(fmt.Sprintf("%s (%d)", cat.Name, len(items)), listHolder))
+	}
+
+	checkBox := container.NewVBox()
+	for _, cat := range repairCategories {
+		if check, ok := checks[cat.Name]; ok {
+			checkBox.Add(check)
+		}
+	}
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(fmt.Sprintf("%d problem(s) found", report.count()), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Select what to fix:"),
+		checkBox,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Details", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		accordion,
+	)
+
+	d := dialog.NewCustomConfirm("Verify & Repair", "Repair Selected", "Cancel", container.NewVScroll(content), func(repair bool) {
+		toDownload := []FileEntry{}
+		toDelete := []string{}
+		if repair {
+			for name, check := range checks {
+				if !check.Checked {
+					continue
+				}
+				for _, item := range report.Items[name] {
+					if name == repairObsolete {
+						toDelete = append(toDelete, item.Path)
+					} else {
+						toDownload = append(toDownload, item.File)
+					}
+				}
+			}
+		}
+
+		if len(toDownload) == 0 && len(toDelete) == 0 {
+			statusLabel.SetText(fmt.Sprintf("⚠️ %d problem(s) found - not repaired", report.count()))
+			playButton.Enable()
+			setBusy(false)
+			return
+		}
+
+		// Reuse the normal update flow for the status label and progress bar
+		go performUpdate(win, manifest, toDownload, toDelete)
+	}, win)
+	d.Resize(fyne.NewSize(600, 500))
+	d.Show()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Problem categories shown in the repair report
+const (
+	repairMissing  = "Missing"
+	repairOutdated = "Outdated"
+	repairCorrupt  = "Corrupt"
+	repairModified = "Modified by you"
+	repairObsolete = "Obsolete"
+)
+
+// Display order and whether each category is fixed by default. Files the
+// player changed on purpose (custom UI tweaks etc.) are left alone unless
+// they opt in.
+var repairCategories = []struct {
+	Name        string
+	Description string
+	Default     bool
+}{
+	{repairMissing, "files that should exist but don't", true},
+	{repairOutdated, "a newer version is on the server", true},
+	{repairCorrupt, "changed without being modified - damaged on disk", true},
+	{repairModified, "edited since the patcher installed them", false},
+	{repairObsolete, "no longer part of the patch", true},
+}
+
+// repairItem is one file in the repair report
+type repairItem struct {
+	File FileEntry // manifest entry (not set for obsolete files)
+	Path string
+	Size int64 // bytes to download, or size on disk for obsolete files
+}
+
+// repairReport groups every problem found by a full verify
+type repairReport struct {
+	Items map[string][]repairItem
+}
+
+func (r *repairReport) count() int {
+	total := 0
+	for _, items := range r.Items {
+		total += len(items)
+	}
+	return total
+}
+
+func (r *repairReport) size(category string) int64 {
+	var total int64
+	for _, item := range r.Items[category] {
+		total += item.Size
+	}
+	return total
+}
+
+// buildRepairReport sorts verification problems into categories. A file
+// that doesn't match is outdated if the patcher last installed a different
+// version of it. If it installed this exact version, the file was modified
+// by the player when its timestamp has changed since, and is corrupt
+// otherwise. Files with no install record count as modified, so they are
+// only replaced if the player asks.
+func buildRepairReport(manifest *Manifest, results []FileStatus) *repairReport {
+	report := &repairReport{Items: make(map[string][]repairItem)}
+
+	installed := make(map[string]installedFile)
+	if local := loadLocalManifest(); local != nil {
+		for _, file := range local.Files {
+			installed[filepath.ToSlash(file.Path)] = installedFile{MD5: file.MD5}
+		}
+		for path, file := range local.Installed {
+			installed[path] = file
+		}
+	}
+
+	for _, result := range results {
+		item := repairItem{File: result.File, Path: result.Path, Size: result.Size}
+		record, known := installed[filepath.ToSlash(result.Path)]
+
+		category := ""
+		switch {
+		case result.Status == statusOK:
+			continue
+		case result.Status == statusMissing:
+			category = repairMissing
+		case record.MD5 != result.File.MD5:
+			category = repairOutdated
+		case !known || record.ModTime == 0:
+			category = repairModified
+		default:
+			category = repairCorrupt
+			if info, err := os.Stat(result.Path); err == nil && info.ModTime().UnixNano() != record.ModTime {
+				category = repairModified
+			}
+		}
+		report.Items[category] = append(report.Items[category], item)
+	}
+
+	for _, filePath := range findObsoleteFiles(manifest) {
+		item := repairItem{Path: filePath}
+		info, err := os.Stat(filePath)
+		if err != nil {
+			continue // Already gone
+		}
+		item.Size = info.Size()
+		report.Items[repairObsolete] = append(report.Items[repairObsolete], item)
+	}
+
+	return report
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildRepairReport(t *testing.T) {
+	installed := &Manifest{Version: "1", Files: []FileEntry{
+		{Path: "edited.txt", MD5: "v1"},
+		{Path: "damaged.txt", MD5: "v1"},
+		{Path: "deleted.txt", MD5: "v1"},
+		{Path: "updated.txt", MD5: "v1"},
+		{Path: "ok.txt", MD5: "v1"},
+	}}
+	server := &Manifest{Version: "2", Files: append([]FileEntry{}, installed.Files...)}
+	server.Files[3].MD5 = "v2"
+
+	tests := []struct {
+		name     string
+		repaired []string // fixed and saved as matching after the damage
+		unknown  bool     // local manifest from before install records
+		want     []string // category:path
+	}{
+		{
+			name: "after a patch",
+			want: []string{
+				"Corrupt:damaged.txt",
+				"Missing:deleted.txt",
+				"Modified by you:edited.txt",
+				"Outdated:updated.txt",
+			},
+		},
+		{
+			// Saving the local manifest must not make edits look like damage
+			name:     "after a partial repair",
+			repaired: []string{"damaged.txt", "deleted.txt"},
+			want: []string{
+				"Modified by you:edited.txt",
+				"Outdated:updated.txt",
+			},
+		},
+		{
+			name:    "no install records",
+			unknown: true,
+			want: []string{
+				"Missing:deleted.txt",
+				"Modified by you:damaged.txt",
+				"Modified by you:edited.txt",
+				"Outdated:updated.txt",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inTempGameFolder(t)
+			files := map[string]string{}
+			for _, file := range installed.Files {
+				files[file.Path] = "v1"
+			}
+			writeFiles(t, files)
+			if tt.unknown {
+				writeLocalManifestForTest(t, installed)
+			} else {
+				saveLocalManifest(installed, manifestPaths(installed))
+			}
+
+			// The player edits one file; another changes without a new
+			// modification time, as disk damage does
+			later := time.Now().Add(time.Hour)
+			writeFiles(t, map[string]string{"edited.txt": "mine", "damaged.txt": "??"})
+			if err := os.Chtimes("edited.txt", later, later); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.unknown {
+				mtime := time.Unix(0, loadLocalManifest().Installed["damaged.txt"].ModTime)
+				if err := os.Chtimes("damaged.txt", mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+			os.Remove("deleted.txt")
+
+			if tt.repaired != nil {
+				for _, path := range tt.repaired {
+					writeFiles(t, map[string]string{path: "v1"})
+				}
+				saveLocalManifest(installed, tt.repaired)
+			}
+
+			report := buildRepairReport(server, repairTestResults(server))
+			got := []string{}
+			for category, items := range report.Items {
+				for _, item := range items {
+					got = append(got, category+":"+item.Path)
+				}
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("report = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// repairTestResults checks the game folder against manifest, where a file
+// matches if it contains its manifest MD5
+func repairTestResults(manifest *Manifest) []FileStatus {
+	results := []FileStatus{}
+	for _, file := range manifest.Files {
+		result := FileStatus{File: file, Path: file.Path, Status: statusOK}
+		data, err := os.ReadFile(file.Path)
+		switch {
+		case err != nil:
+			result.Status = statusMissing
+		case string(data) != file.MD5:
+			result.Status = statusHashMismatch
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// writeLocalManifestForTest saves manifest as the local manifest as older
+// versions did, without install records
+func writeLocalManifestForTest(t *testing.T, manifest *Manifest) {
+	t.Helper()
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(localManifestFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+)
+
+// validateManifestPath makes sure a path taken from a manifest stays inside
+// the game folder. Manifest paths must be relative, use no ".." components,
+// and contain no drive letters, stream names or NUL bytes.
+func validateManifestPath(p string) error {
+	if p == "" {
+		return fmt.Errorf("unsafe manifest path: empty path")
+	}
+	if strings.ContainsRune(p, 0) {
+		return fmt.Errorf("unsafe manifest path %q: contains a NUL byte", p)
+	}
+	// Catches drive letters (C:\...) and NTFS alternate data streams (file:stream)
+	if strings.Contains(p, ":") {
+		return fmt.Errorf("unsafe manifest path %q: contains a drive letter or ':'", p)
+	}
+	// Catches /abs/path, \abs\path and \\server\share
+	if strings.HasPrefix(p, "/") || strings.HasPrefix(p, `\`) || filepath.IsAbs(p) {
+		return fmt.Errorf("unsafe manifest path %q: absolute paths are not allowed", p)
+	}
+
+	// Check components with both separators, whatever OS we're on
+	for _, part := range strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return fmt.Errorf("unsafe manifest path %q: contains '..'", p)
+		}
+	}
+
+	return nil
+}
+
+// checkManifestPath validates a manifest path and writes a security log line
+// when it is rejected
+func checkManifestPath(p string) error {
+	err := validateManifestPath(p)
+	if err != nil {
+		slog.Error("SECURITY: refusing manifest entry", "error", err)
+	}
+	return err
+}
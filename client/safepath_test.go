@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestValidateManifestPath(t *testing.T) {
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{"eqgame.exe", true},
+		{"Resources/spells_us.txt", true},
+		{`uifiles\default\EQUI.xml`, true},
+		{"maps/..foo/bar.txt", true}, // ".." only as a whole component
+		{"a/./b.txt", true},
+
+		{"", false},
+		{"../eqgame.exe", false},
+		{"maps/../../evil.dll", false},
+		{`maps\..\..\evil.dll`, false},
+		{"maps/..", false},
+		{"/etc/passwd", false},
+		{`\Windows\System32\evil.dll`, false},
+		{`\\server\share\evil.dll`, false},
+		{`C:\Windows\evil.dll`, false},
+		{"C:evil.dll", false},
+		{"eqgame.exe:hidden", false},
+		{"eq\x00game.exe", false},
+	}
+	for _, tt := range tests {
+		err := validateManifestPath(tt.path)
+		if (err == nil) != tt.ok {
+			t.Errorf("validateManifestPath(%q) = %v, want ok=%v", tt.path, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package secret
+
+import "errors"
+
+var errNoDPAPI = errors.New("DPAPI is only available on Windows")
+
+func protect(data []byte) ([]byte, error) {
+	return nil, errNoDPAPI
+}
+
+func unprotect(data []byte) ([]byte, error) {
+	return nil, errNoDPAPI
+}
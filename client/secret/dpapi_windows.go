@@ -0,0 +1,68 @@
+package secret
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Don't show a prompt if DPAPI would like to ask the user something
+const cryptProtectUIForbidden = 0x1
+
+type dataBlob struct {
+	size uint32
+	data *byte
+}
+
+func newBlob(data []byte) *dataBlob {
+	if len(data) == 0 {
+		return &dataBlob{}
+	}
+	return &dataBlob{size: uint32(len(data)), data: &data[0]}
+}
+
+// bytes copies the blob out and frees the memory Windows allocated for it
+func (b *dataBlob) bytes() []byte {
+	kernel32 := syscall.NewLazyDLL("kernel32.dll")
+	localFree := kernel32.NewProc("LocalFree")
+
+	out := make([]byte, b.size)
+	copy(out, unsafe.Slice(b.data, b.size))
+	localFree.Call(uintptr(unsafe.Pointer(b.data)))
+	return out
+}
+
+// protect encrypts data for the current Windows user
+func protect(data []byte) ([]byte, error) {
+	crypt32 := syscall.NewLazyDLL("crypt32.dll")
+	cryptProtectData := crypt32.NewProc("CryptProtectData")
+
+	var out dataBlob
+	ret, _, callErr := cryptProtectData.Call(
+		uintptr(unsafe.Pointer(newBlob(data))),
+		0, 0, 0, 0,
+		cryptProtectUIForbidden,
+		uintptr(unsafe.Pointer(&out)),
+	)
+	if ret == 0 {
+		return nil, callErr
+	}
+	return out.bytes(), nil
+}
+
+// unprotect decrypts data sealed by protect
+func unprotect(data []byte) ([]byte, error) {
+	crypt32 := syscall.NewLazyDLL("crypt32.dll")
+	cryptUnprotectData := crypt32.NewProc("CryptUnprotectData")
+
+	var out dataBlob
+	ret, _, callErr := cryptUnprotectData.Call(
+		uintptr(unsafe.Pointer(newBlob(data))),
+		0, 0, 0, 0,
+		cryptProtectUIForbidden,
+		uintptr(unsafe.Pointer(&out)),
+	)
+	if ret == 0 {
+		return nil, callErr
+	}
+	return out.bytes(), nil
+}
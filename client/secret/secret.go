@@ -0,0 +1,72 @@
+// Package secret protects credentials stored in the config file. On Windows
+// values are encrypted with DPAPI, so only the same Windows user on the same
+// PC can read them back. Elsewhere (or if DPAPI fails) they are only
+// obfuscated, which keeps them from being read at a glance but is not
+// encryption.
+package secret
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+const (
+	dpapiPrefix      = "dpapi:"
+	obfuscatedPrefix = "obf:"
+)
+
+// Not a secret - just enough to stop the value being readable
+var obfuscationKey = []byte("simple-eq-patcher")
+
+// Seal protects a value for storage. Empty and already sealed values are
+// returned unchanged.
+func Seal(value string) string {
+	if value == "" || IsSealed(value) {
+		return value
+	}
+
+	if data, err := protect([]byte(value)); err == nil {
+		return dpapiPrefix + base64.StdEncoding.EncodeToString(data)
+	}
+	return obfuscatedPrefix + base64.StdEncoding.EncodeToString(xor([]byte(value)))
+}
+
+// Open returns the original value of a sealed one. Values without a prefix
+// were typed into the config file by hand and are returned as they are.
+func Open(stored string) (string, error) {
+	switch {
+	case strings.HasPrefix(stored, dpapiPrefix):
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, dpapiPrefix))
+		if err != nil {
+			return "", fmt.Errorf("stored credential is damaged: %v", err)
+		}
+		plain, err := unprotect(data)
+		if err != nil {
+			return "", fmt.Errorf("stored credential can't be decrypted by this Windows user: %v", err)
+		}
+		return string(plain), nil
+
+	case strings.HasPrefix(stored, obfuscatedPrefix):
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, obfuscatedPrefix))
+		if err != nil {
+			return "", fmt.Errorf("stored credential is damaged: %v", err)
+		}
+		return string(xor(data)), nil
+	}
+
+	return stored, nil
+}
+
+// IsSealed reports whether value was produced by Seal
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, dpapiPrefix) || strings.HasPrefix(value, obfuscatedPrefix)
+}
+
+func xor(data []byte) []byte {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[i] = b ^ obfuscationKey[i%len(obfuscationKey)]
+	}
+	return out
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+const (
+	// Set for the relaunched launcher; holds the path of the marker file it
+	// must create once it has started successfully
+	selfUpdateHealthEnv = "EQPATCHER_SELF_UPDATE_HEALTH"
+
+	// How long the updated launcher has to pass its startup health check
+	// before we roll back to the previous version
+	selfUpdateHealthTimeout = 30 * time.Second
+)
+
+// launcherBinaryPath maps a launcher manifest entry to the local file it
+// replaces. LaunchPad.exe is always the running executable, even if the
+// player has renamed it.
+func launcherBinaryPath(name string) (string, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("could not determine launcher location: %v", err)
+	}
+	if name == "LaunchPad.exe" {
+		return exePath, nil
+	}
+	return filepath.Join(filepath.Dir(exePath), name), nil
+}
+
+// performSelfUpdate downloads and installs new launcher binaries. If
+// LaunchPad.exe itself was replaced, the new version is started with the same
+// arguments and this process exits once it reports healthy; otherwise the old
+// binaries are restored and game file checking continues.
+func performSelfUpdate(win fyne.Window, manifest *Manifest, outdated []FileEntry, fullVerify bool) {
+	playButton.Disable()
+	setBusy(true)
+	progressBar.Show()
+	progressBar.SetValue(0)
+
+	restart := ""
+	swapped := []string{}
+	for i, file := range outdated {
+		progressBar.SetValue(float64(i) / float64(len(outdated)))
+		statusLabel.SetText(fmt.Sprintf("📥 Updating %s (%d/%d)", file.Path, i+1, len(outdated)))
+
+		target, err := launcherBinaryPath(file.Path)
+		if err == nil {
+			err = installLauncherBinary(file, target)
+		}
+		if err != nil {
+			rollbackLauncherBinaries(swapped)
+			selfUpdateFailed(win, manifest, fullVerify, fmt.Errorf("%s: %v", file.Path, err))
+			return
+		}
+
+		swapped = append(swapped, target)
+		if file.Path == "LaunchPad.exe" {
+			restart = target
+		}
+	}
+	progressBar.SetValue(1.0)
+
+	if restart == "" {
+		// Only patcher.exe changed - nothing is running it, so we're done
+		cleanupSelfUpdate()
+		go checkGameFiles(win, manifest, fullVerify)
+		return
+	}
+
+	statusLabel.SetText("🔄 Restarting launcher...")
+	err := restartUpdatedLauncher(restart)
+	if err != nil {
+		rollbackLauncherBinaries(swapped)
+		selfUpdateFailed(win, manifest, fullVerify, err)
+		return
+	}
+
+	// The new launcher is up and running
+	os.Exit(0)
+}
+
+// installLauncherBinary downloads a launcher binary next to target, verifies
+// it against the manifest and swaps it into place
+func installLauncherBinary(file FileEntry, target string) error {
+	newPath := target + ".new"
+
+	err := downloadFileTo(config.ServerURL, file.Path, newPath)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(newPath)
+	if err != nil {
+		os.Remove(newPath)
+		return err
+	}
+	if info.Size() != file.Size {
+		os.Remove(newPath)
+		return fmt.Errorf("downloaded file is %d bytes, expected %d", info.Size(), file.Size)
+	}
+	hash, err := calculateMD5(newPath)
+	if err != nil || hash != file.MD5 {
+		os.Remove(newPath)
+		return fmt.Errorf("downloaded file failed checksum verification")
+	}
+
+	err = swapBinary(target, newPath)
+	if err != nil {
+		os.Remove(newPath)
+		return err
+	}
+
+	return nil
+}
+
+// swapBinary replaces target with newPath, keeping the previous version as
+// target.old. The running executable can't be overwritten on Windows but it
+// can be renamed, so it is moved aside and the new one takes its name; the
+// old file is deleted on the next start. If renaming fails we fall back to
+// copying.
+func swapBinary(target, newPath string) error {
+	oldPath := target + ".old"
+	os.Remove(oldPath)
+
+	if _, err := os.Stat(target); err == nil {
+		err = os.Rename(target, oldPath)
+		if err != nil {
+			// Fallback: keep a copy as the backup and overwrite in place
+			if err := copyFile(target, oldPath); err != nil {
+				return fmt.Errorf("could not back up %s: %v", filepath.Base(target), err)
+			}
+			if err := copyFile(newPath, target); err != nil {
+				return fmt.Errorf("could not replace %s: %v", filepath.Base(target), err)
+			}
+			os.Remove(newPath)
+			return nil
+		}
+	}
+
+	err := os.Rename(newPath, target)
+	if err != nil {
+		// Fallback: copy the new binary into place
+		if err := copyFile(newPath, target); err != nil {
+			restoreLauncherBinary(target)
+			return fmt.Errorf("could not install %s: %v", filepath.Base(target), err)
+		}
+		os.Remove(newPath)
+	}
+
+	return nil
+}
+
+// restartUpdatedLauncher starts the new launcher with our arguments and
+// waits for it to pass its startup health check
+func restartUpdatedLauncher(exePath string) error {
+	marker := exePath + ".health"
+	os.Remove(marker)
+
+	cmd := exec.Command(exePath, os.Args[1:]...)
+	cmd.Dir = filepath.Dir(exePath)
+	cmd.Env = append(os.Environ(), selfUpdateHealthEnv+"="+marker)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start updated launcher: %v", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(selfUpdateHealthTimeout)
+
+	for {
+		select {
+		case err := <-exited:
+			// The player may have closed it straight away after it started
+			if _, statErr := os.Stat(marker); statErr == nil {
+				os.Remove(marker)
+				return nil
+			}
+			if err == nil {
+				return fmt.Errorf("updated launcher exited during startup")
+			}
+			return fmt.Errorf("updated launcher exited during startup: %v", err)
+
+		case <-ticker.C:
+			if _, err := os.Stat(marker); err == nil {
+				os.Remove(marker)
+				return nil
+			}
+
+		case <-timeout:
+			cmd.Process.Kill()
+			<-exited
+			return fmt.Errorf("updated launcher did not start within %v", selfUpdateHealthTimeout)
+		}
+	}
+}
+
+// reportSelfUpdateHealthy is called by a freshly updated launcher once its
+// window is up, letting the previous launcher exit
+func reportSelfUpdateHealthy(marker string) {
+	os.WriteFile(marker, []byte(time.Now().Format(time.RFC3339)), 0644)
+}
+
+// rollbackLauncherBinaries restores the .old copy of each replaced binary
+func rollbackLauncherBinaries(targets []string) {
+	for _, target := range targets {
+		restoreLauncherBinary(target)
+	}
+}
+
+// restoreLauncherBinary puts target.old back in place of target
+func restoreLauncherBinary(target string) {
+	oldPath := target + ".old"
+	if _, err := os.Stat(oldPath); err != nil {
+		return
+	}
+
+	// The failed binary may take a moment to unlock after being killed
+	for i := 0; i < 10; i++ {
+		err := os.Remove(target)
+		if err == nil || os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	if err := os.Rename(oldPath, target); err != nil {
+		copyFile(oldPath, target)
+	}
+}
+
+// cleanupSelfUpdate removes files left over from a previous self-update.
+// It is skipped while a health check is pending, since the .old files are
+// still needed for a rollback.
+func cleanupSelfUpdate() {
+	for name := range launcherBinaries {
+		target, err := launcherBinaryPath(name)
+		if err != nil {
+			continue
+		}
+		os.Remove(target + ".old")
+		os.Remove(target + ".new")
+		os.Remove(target + ".new.tmp")
+		os.Remove(target + ".health")
+	}
+}
+
+func selfUpdateFailed(win fyne.Window, manifest *Manifest, fullVerify bool, err error) {
+	statusLabel.SetText("⚠️ Launcher update failed")
+	progressBar.Hide()
+	showError(win, fmt.Sprintf("Launcher update failed: %v\n\nYour previous launcher version has been kept.", err))
+
+	// Continue checking game files anyway
+	go checkGameFiles(win, manifest, fullVerify)
+}
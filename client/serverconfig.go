@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// ServerConfig holds optional settings the server admin publishes as
+// server-config.json next to manifest.json. Servers without one get the
+// defaults.
+type ServerConfig struct {
+	MaxBoxes       int    `json:"max_boxes,omitempty"`        // game instances one LaunchPad may run at once, 0 = no limit
+	CrashReportURL string `json:"crash_report_url,omitempty"` // where crash reports are sent, absolute or relative to server_url
+}
+
+// serverURLFor resolves a URL from server-config.json against the patch
+// server URL
+func serverURLFor(serverURL, ref string) string {
+	if ref == "" || strings.Contains(ref, "://") {
+		return ref
+	}
+	return strings.TrimRight(serverURL, "/") + "/" + strings.TrimLeft(ref, "/")
+}
+
+func serverConfigURL(serverURL string) string {
+	return strings.TrimRight(serverURL, "/") + "/server-config.json"
+}
+
+// downloadServerConfig fetches server-config.json, falling back to the last
+// copy we saw if the server can't be reached
+func downloadServerConfig(serverURL string) (*ServerConfig, error) {
+	url := serverConfigURL(serverURL)
+
+	data, err := cachedGet(url)
+	if isNotFound(err) {
+		// Remember that there is none, replacing any older copy
+		entry := &cacheEntry{URL: url, Checked: time.Now()}
+		if err := entry.save([]byte("{}")); err != nil {
+			slog.Debug("could not cache server config", "error", err)
+		}
+		return &ServerConfig{}, nil
+	}
+	if err != nil {
+		cached, _, cacheErr := cachedCopy(url)
+		if cacheErr != nil {
+			return nil, err
+		}
+		data = cached
+	}
+
+	var serverConfig ServerConfig
+	if err := json.Unmarshal(data, &serverConfig); err != nil {
+		return nil, err
+	}
+	return &serverConfig, nil
+}
+
+// cachedServerConfig returns the copy of server-config.json saved by the
+// last downloadServerConfig, without asking the server
+func cachedServerConfig(serverURL string) (*ServerConfig, error) {
+	data, _, err := cachedCopy(serverConfigURL(serverURL))
+	if err != nil {
+		return nil, err
+	}
+	var serverConfig ServerConfig
+	if err := json.Unmarshal(data, &serverConfig); err != nil {
+		return nil, err
+	}
+	return &serverConfig, nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+)
+
+// The line under the server name shows whether the game server is up, so
+// players don't patch and launch only to find it down. It comes from a TCP
+// connect to login_server and, if the server publishes one, status_url.
+
+const (
+	statusCheckInterval = 60 * time.Second // how often the status line is refreshed
+	statusCheckTimeout  = 4 * time.Second  // per connect or request
+)
+
+var (
+	statusOnline  = color.RGBA{R: 90, G: 210, B: 120, A: 255}
+	statusWarning = color.RGBA{R: 240, G: 190, B: 70, A: 255}
+	statusOffline = color.RGBA{R: 235, G: 85, B: 85, A: 255}
+)
+
+// statusReport is what status_url returns. Every field is optional.
+type statusReport struct {
+	PlayersOnline *int   `json:"players_online"`
+	WorldUp       *bool  `json:"world_up"`
+	UptimeSeconds *int64 `json:"uptime_seconds"`
+}
+
+// serverStatus is the result of one status check
+type serverStatus struct {
+	Checked   bool          // a login server was probed
+	Reachable bool          // the TCP connect worked
+	Latency   time.Duration // how long the connect took
+	Err       error         // why it didn't
+	Report    *statusReport // nil without status_url, or if it failed
+}
+
+// offline reports whether the game can't be played right now: the login
+// server doesn't answer or the status endpoint says the world is down
+func (s *serverStatus) offline() bool {
+	if s.Checked && !s.Reachable {
+		return true
+	}
+	return s.Report != nil && s.Report.WorldUp != nil && !*s.Report.WorldUp
+}
+
+// describe is the status line, e.g. "● Online · 42 ms · 153 players · up 3d 4h"
+func (s *serverStatus) describe() (string, color.Color) {
+	if s.Checked && !s.Reachable {
+		return "● Login server offline", statusOffline
+	}
+	if s.Report == nil && !s.Checked {
+		return "● Server status unknown", theme.ForegroundColor()
+	}
+
+	parts := []string{"● Online"}
+	c := color.Color(statusOnline)
+	if s.Report != nil && s.Report.WorldUp != nil && !*s.Report.WorldUp {
+		parts = []string{"● World server down"}
+		c = statusWarning
+	}
+	if s.Checked {
+		parts = append(parts, fmt.Sprintf("%d ms", s.Latency.Milliseconds()))
+	}
+	if s.Report != nil {
+		if s.Report.PlayersOnline != nil {
+			parts = append(parts, fmt.Sprintf("%d players", *s.Report.PlayersOnline))
+		}
+		if s.Report.UptimeSeconds != nil {
+			parts = append(parts, "up "+formatUptime(time.Duration(*s.Report.UptimeSeconds)*time.Second))
+		}
+	}
+	return strings.Join(parts, " · "), c
+}
+
+// checkServerStatus probes the login server and asks status_url, whichever
+// are configured
+func checkServerStatus(cfg *Config) *serverStatus {
+	status := &serverStatus{}
+
+	if cfg.LoginServer != "" {
+		address := loginAddress(cfg.LoginServer)
+		if cfg.LoginCheckPort > 0 {
+			host, _, _ := net.SplitHostPort(address)
+			address = net.JoinHostPort(host, strconv.Itoa(cfg.LoginCheckPort))
+		}
+		status.Checked = true
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", address, statusCheckTimeout)
+		if err == nil {
+			status.Reachable = true
+			status.Latency = time.Since(start)
+			conn.Close()
+		} else {
+			status.Err = err
+		}
+		slog.Debug("login server check", "address", address, "reachable", status.Reachable, "latency", status.Latency, "error", err)
+	}
+
+	if cfg.StatusURL != "" {
+		report, err := fetchStatusReport(serverURLFor(cfg.ServerURL, cfg.StatusURL), cfg.ServerURL)
+		if err != nil {
+			slog.Debug("status endpoint failed", "url", cfg.StatusURL, "error", err)
+		}
+		status.Report = report
+	}
+	return status
+}
+
+// fetchStatusReport reads the server's JSON status endpoint
+func fetchStatusReport(statusURL, serverURL string) (*statusReport, error) {
+	// Credentials only go to the patch server itself
+	newReq := http.NewRequest
+	if sameHost(statusURL, serverURL) {
+		newReq = newRequest
+	}
+	req, err := newReq(http.MethodGet, statusURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := *httpClient
+	client.Timeout = statusCheckTimeout
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{resp.StatusCode}
+	}
+
+	var report statusReport
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&report); err != nil {
+		return nil, fmt.Errorf("invalid status response: %v", err)
+	}
+	return &report, nil
+}
+
+// serverStatusLabel shows the status line under the server name
+var serverStatusLabel *canvas.Text
+
+// statusGeneration is bumped whenever the status checks restart, so the
+// checks for the previous server stop
+var statusGeneration int64
+
+// createServerStatusLabel creates the status line; startStatusChecks fills it
+func createServerStatusLabel() *canvas.Text {
+	serverStatusLabel = canvas.NewText("", theme.ForegroundColor())
+	serverStatusLabel.TextSize = 12
+	serverStatusLabel.Alignment = fyne.TextAlignCenter
+	serverStatusLabel.Hide()
+	return serverStatusLabel
+}
+
+// startStatusChecks checks the active server's status now and every minute,
+// replacing the checks for any previous server. The status line stays
+// hidden if neither login_server nor status_url is set.
+func startStatusChecks() {
+	generation := atomic.AddInt64(&statusGeneration, 1)
+	cfg := config
+
+	if cfg.LoginServer == "" && cfg.StatusURL == "" {
+		serverStatusLabel.Hide()
+		return
+	}
+	serverStatusLabel.Text = "Checking server status..."
+	serverStatusLabel.Color = theme.ForegroundColor()
+	serverStatusLabel.Show()
+	serverStatusLabel.Refresh()
+
+	go func() {
+		for {
+			status := checkServerStatus(cfg)
+			if atomic.LoadInt64(&statusGeneration) != generation {
+				return // Switched server in the meantime
+			}
+			showServerStatus(status)
+
+			time.Sleep(statusCheckInterval)
+			if atomic.LoadInt64(&statusGeneration) != generation {
+				return
+			}
+		}
+	}()
+}
+
+// showServerStatus puts status in the status line
+func showServerStatus(status *serverStatus) {
+	text, c := status.describe()
+	serverStatusLabel.Text = text
+	serverStatusLabel.Color = c
+	serverStatusLabel.Refresh()
+}
+
+// confirmLaunchIfOffline checks the server again right before launching and,
+// if it is down, asks whether to launch anyway. Returns true to go ahead.
+func confirmLaunchIfOffline(win fyne.Window) bool {
+	if config.LoginServer == "" && config.StatusURL == "" {
+		return true
+	}
+	status := checkServerStatus(config)
+	showServerStatus(status)
+	if !status.offline() {
+		return true
+	}
+
+	message := fmt.Sprintf("%s appears to be down", serverDisplayName())
+	if status.Checked && !status.Reachable {
+		message += fmt.Sprintf(":\n\nCould not reach the login server %s.", loginAddress(config.LoginServer))
+	} else {
+		message += ":\n\nThe world server is not up."
+	}
+	slog.Warn("server offline at launch", "login_server", config.LoginServer, "error", status.Err)
+
+	answer := make(chan bool)
+	dialog.ShowConfirm("Server Offline", message+"\n\nLaunch the game anyway?", func(ok bool) {
+		answer <- ok
+	}, win)
+	return <-answer
+}
+
+// formatUptime formats a server uptime for display, e.g. "3d 4h"
+func formatUptime(d time.Duration) string {
+	if d >= 24*time.Hour {
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+	return formatDuration(d)
+}
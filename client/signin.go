@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// promptSignIn asks for the patch server credentials after a 401. retry is
+// run in the background once they've been saved; cancel runs if the player
+// gives up.
+func promptSignIn(win fyne.Window, retry func(), cancel func()) {
+	statusLabel.SetText("🔒 Sign-in required")
+	progressBar.Hide()
+
+	bearer := usesBearerAuth()
+
+	username := widget.NewEntry()
+	password := widget.NewPasswordEntry()
+	token := widget.NewPasswordEntry()
+
+	var items []*widget.FormItem
+	if bearer {
+		items = []*widget.FormItem{widget.NewFormItem("Access token", token)}
+	} else {
+		authMu.Lock()
+		if config.Auth != nil {
+			username.SetText(config.Auth.Username)
+		}
+		authMu.Unlock()
+		items = []*widget.FormItem{
+			widget.NewFormItem("Username", username),
+			widget.NewFormItem("Password", password),
+		}
+	}
+
+	d := dialog.NewForm(fmt.Sprintf("Sign in to %s", serverDisplayName()), "Sign In", "Cancel", items, func(ok bool) {
+		if !ok {
+			cancel()
+			return
+		}
+
+		var err error
+		if bearer {
+			err = setCredentials("", "", token.Text)
+		} else {
+			err = setCredentials(username.Text, password.Text, "")
+		}
+		if err != nil {
+			showError(win, fmt.Sprintf("Could not save your sign-in details: %v", err))
+		}
+		go retry()
+	}, win)
+	d.Resize(fyne.NewSize(400, 0))
+	d.Show()
+}
+
+// serverDisplayName is the server name from the config, or its URL
+func serverDisplayName() string {
+	if config.ServerName != "" {
+		return config.ServerName
+	}
+	return config.ServerURL
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// With stay_resident set, LaunchPad stays open while the game runs instead
+// of exiting, so it can tell when the game crashed and help the player.
+// Games started by a multi-box launch are always watched.
+
+// Windows exception codes a crashed game commonly exits with
+var crashCodeNames = map[uint32]string{
+	0xC0000005: "access violation",
+	0xC000001D: "illegal instruction",
+	0xC0000094: "divide by zero",
+	0xC00000FD: "stack overflow",
+	0xC0000374: "heap corruption",
+	0xC0000409: "stack buffer overrun",
+}
+
+// gameSession is how one run of the game ended
+type gameSession struct {
+	Launch   string
+	Started  time.Time
+	Duration time.Duration
+	ExitCode int
+}
+
+// abnormal reports whether the game ended in a crash rather than being
+// quit by the player
+func (s *gameSession) abnormal() bool {
+	return s.ExitCode != 0
+}
+
+// describeExit explains the exit code, e.g. "0xC0000005 (access violation)"
+func (s *gameSession) describeExit() string {
+	if s.ExitCode == -1 {
+		return "killed"
+	}
+	code := uint32(s.ExitCode)
+	if name, ok := crashCodeNames[code]; ok {
+		return fmt.Sprintf("0x%08X (%s)", code, name)
+	}
+	if code > 0xFFFF {
+		return fmt.Sprintf("0x%08X", code)
+	}
+	return fmt.Sprintf("%d", s.ExitCode)
+}
+
+// gameExited is called by the instance watcher once a game has exited
+func gameExited(win fyne.Window, session *gameSession) {
+	slog.Info("game session ended", "launch_profile", session.Launch, "exit_code", session.ExitCode,
+		"session", session.Duration.Round(time.Second), "abnormal", session.abnormal())
+
+	if !session.abnormal() {
+		if runningInstances() == 0 {
+			statusLabel.SetText(fmt.Sprintf("Ready to play - last session %s", formatDuration(session.Duration)))
+		} else {
+			showRunningStatus()
+		}
+		return
+	}
+
+	slog.Warn("game crashed", "exit_code", session.describeExit(), "session", session.Duration.Round(time.Second))
+	showRunningStatus()
+	showCrashDialog(win, session)
+}
+
+// showCrashDialog tells the player the game crashed and offers to relaunch,
+// repair the game files or save the crash files for a bug report
+func showCrashDialog(win fyne.Window, session *gameSession) {
+	message := fmt.Sprintf("EverQuest closed unexpectedly after %s.\nExit code: %s",
+		formatDuration(session.Duration), session.describeExit())
+	artifacts := crashArtifacts(session.Started)
+	if len(artifacts) > 0 {
+		message += fmt.Sprintf("\n\n%d crash file(s) found (dbg.txt, crash dumps).", len(artifacts))
+	}
+
+	d := dialog.NewCustomWithoutButtons("Game Crashed", widget.NewLabel(message), win)
+	relaunch := widget.NewButton("Relaunch", func() {
+		d.Hide()
+		go func() {
+			err := checkBoxLimit(1)
+			if err == nil {
+				err = startInstance(win, findLaunchProfile(config.LaunchProfiles, session.Launch))
+			}
+			if err != nil {
+				showError(win, fmt.Sprintf("Failed to launch game: %v", err))
+			}
+			showRunningStatus()
+		}()
+	})
+	relaunch.Importance = widget.HighImportance
+	repair := widget.NewButton("Verify & Repair", func() {
+		d.Hide()
+		go startRepair(win)
+	})
+	collect := widget.NewButton("Save Crash Files...", func() {
+		d.Hide()
+		saveCrashFiles(win, artifacts)
+	})
+	if len(artifacts) == 0 {
+		collect.Disable()
+	}
+	buttons := []fyne.CanvasObject{relaunch, repair, collect}
+	if reportURL := crashReportURL(); reportURL != "" {
+		buttons = append(buttons, widget.NewButton("Send Report...", func() {
+			d.Hide()
+			confirmSendCrashReport(win, reportURL, session)
+		}))
+	}
+	d.SetButtons(append(buttons, widget.NewButton("Close", d.Hide)))
+	d.Show()
+}
+
+// crashArtifacts lists the files the game leaves behind when it crashes:
+// dbg.txt and any crash dumps, if written since the session started
+func crashArtifacts(since time.Time) []string {
+	candidates := []string{
+		filepath.Join(gameFolder, "dbg.txt"),
+		filepath.Join(gameFolder, "Logs", "dbg.txt"),
+	}
+	dumps, _ := filepath.Glob(filepath.Join(gameFolder, "*.dmp"))
+	candidates = append(candidates, dumps...)
+	if local := os.Getenv("LOCALAPPDATA"); local != "" {
+		dumps, _ := filepath.Glob(filepath.Join(local, "CrashDumps", "eqgame.exe.*.dmp"))
+		candidates = append(candidates, dumps...)
+	}
+
+	found := []string{}
+	for _, path := range candidates {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		// Anything older is from an earlier session
+		if info.ModTime().Before(since) {
+			continue
+		}
+		found = append(found, path)
+	}
+	return found
+}
+
+// saveCrashFiles zips the crash files wherever the player chooses
+func saveCrashFiles(win fyne.Window, artifacts []string) {
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil || writer == nil {
+			return
+		}
+		zw := zip.NewWriter(writer)
+		for _, path := range artifacts {
+			if err = addFileToZip(zw, path, filepath.Base(filepath.Dir(path))+"/"+filepath.Base(path)); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = zw.Close()
+		}
+		closeErr := writer.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			showError(win, fmt.Sprintf("Could not save the crash files:\n\n%v", err))
+			return
+		}
+		dialog.ShowInformation("Crash Files", fmt.Sprintf("Saved to:\n%s", writer.URI().Path()), win)
+	}, win)
+	save.SetFileName(fmt.Sprintf("crash-%s.zip", time.Now().Format("20060102-150405")))
+	save.Show()
+}
+
+// formatDuration formats a session length for display, e.g. "1h 12m"
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm %ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+}
+
+// setStayResident turns stay_resident on or off and saves it
+func setStayResident(on bool) {
+	rootConfig.StayResident = on
+	config.StayResident = on
+	saveConfig(rootConfig)
+}
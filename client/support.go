@@ -0,0 +1,212 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+
+	"simple-eq-patcher/client/diskspace"
+)
+
+// A support bundle is a zip a player can attach to a ticket, holding what we
+// would otherwise have to ask for one message at a time: logs, config,
+// eqclient.ini, the patcher's own state and a fresh verification
+
+// Patcher state files copied into the bundle's state/ folder
+var supportStateFiles = []string{
+	localManifestFile,
+	verifyCacheFile,
+	packChoicesFile,
+	backupIndexFile,
+	journalFile,
+}
+
+// createSupportBundle asks where to save the bundle and builds it there
+func createSupportBundle(win fyne.Window) {
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil || writer == nil {
+			return
+		}
+		go func() {
+			playButton.Disable()
+			setBusy(true)
+			defer setBusy(false)
+			defer playButton.Enable()
+
+			progressBar.Show()
+			progressBar.SetValue(0)
+			statusLabel.SetText("Creating support bundle...")
+
+			err := writeSupportBundle(writer, func(done, total int) {
+				progressBar.SetValue(float64(done) / float64(total))
+			})
+			closeErr := writer.Close()
+			if err == nil {
+				err = closeErr
+			}
+			progressBar.Hide()
+
+			if err != nil {
+				slog.Error("support bundle failed", "error", err)
+				statusLabel.SetText("⚠️ Support bundle failed")
+				showError(win, fmt.Sprintf("Could not create the support bundle:\n\n%v", err))
+				return
+			}
+			statusLabel.SetText("✓ Support bundle created - Ready to play")
+			dialog.ShowInformation("Support Bundle",
+				fmt.Sprintf("Saved to:\n%s\n\nAttach this file to your support ticket. Passwords and tokens have been removed.", writer.URI().Path()), win)
+		}()
+	}, win)
+	save.SetFileName(fmt.Sprintf("support-bundle-%s.zip", time.Now().Format("20060102-150405")))
+	save.Show()
+}
+
+// writeSupportBundle writes the bundle zip to w. progress follows the
+// verification, which is most of the work.
+func writeSupportBundle(w io.Writer, progress func(done, total int)) error {
+	zw := zip.NewWriter(w)
+
+	// Logs first, so they still show what happened if verifying fails
+	logs, _ := filepath.Glob(filepath.Join(launcherDir, logDirName, "*.log*"))
+	for _, path := range logs {
+		if err := addFileToZip(zw, path, "logs/"+filepath.Base(path)); err != nil {
+			return err
+		}
+	}
+
+	configData, err := redactedConfig(filepath.Join(launcherDir, configFile))
+	if err != nil {
+		configData = []byte(fmt.Sprintf("could not read %s: %v\n", configFile, err))
+	}
+	if err := addBytesToZip(zw, configFile, configData); err != nil {
+		return err
+	}
+
+	if err := addFileToZip(zw, filepath.Join(gameFolder, "eqclient.ini"), "eqclient.ini"); err != nil {
+		return err
+	}
+
+	for _, path := range supportStateFiles {
+		if err := addFileToZip(zw, path, "state/"+filepath.ToSlash(path)); err != nil {
+			return err
+		}
+	}
+
+	if err := addBytesToZip(zw, "system.txt", []byte(systemInfo())); err != nil {
+		return err
+	}
+	if err := addBytesToZip(zw, "verification.txt", []byte(verificationReport(progress))); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// addFileToZip copies the file at path into the zip as name. Missing files
+// are skipped - not having them is part of the picture.
+func addFileToZip(zw *zip.Writer, path, name string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return addBytesToZip(zw, name+".error.txt", []byte(err.Error()+"\n"))
+	}
+	defer file.Close()
+
+	dst, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, file)
+	return err
+}
+
+func addBytesToZip(zw *zip.Writer, name string, data []byte) error {
+	dst, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(data)
+	return err
+}
+
+// systemInfo describes the PC and the LaunchPad setup
+func systemInfo() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Created:        %s\n", time.Now().Format(time.RFC1123))
+	fmt.Fprintf(&b, "OS:             %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "Built with:     %s\n", runtime.Version())
+	fmt.Fprintf(&b, "LaunchPad:      %s\n", launcherDir)
+	fmt.Fprintf(&b, "Game folder:    %s\n", gameFolder)
+	if name := activeProfileName(); name != "" {
+		fmt.Fprintf(&b, "Profile:        %s\n", name)
+	}
+	fmt.Fprintf(&b, "Server:         %s\n", redactURLUser(config.ServerURL))
+	fmt.Fprintf(&b, "Game exe found: %v\n", isGameFolder(gameFolder, config.GameExe))
+
+	if free, err := diskspace.Available(gameFolder); err != nil {
+		fmt.Fprintf(&b, "Free disk:      unknown (%v)\n", err)
+	} else {
+		fmt.Fprintf(&b, "Free disk:      %s\n", formatBytes(int64(free)))
+	}
+
+	fmt.Fprintf(&b, "\nResolutions:\n")
+	for _, res := range getAvailableResolutions() {
+		fmt.Fprintf(&b, "  %s\n", res)
+	}
+	return b.String()
+}
+
+// verificationReport rehashes every file against the server manifest (or
+// the cached one if the server is unreachable) and lists what's wrong
+func verificationReport(progress func(done, total int)) string {
+	var b strings.Builder
+
+	manifest, err := downloadManifest(config.ServerURL)
+	if err != nil {
+		fmt.Fprintf(&b, "Could not download the manifest: %v\n", err)
+		var checked time.Time
+		manifest, checked, err = lastKnownManifest(config.ServerURL)
+		if err != nil {
+			fmt.Fprintf(&b, "No cached manifest either - nothing verified.\n")
+			return b.String()
+		}
+		fmt.Fprintf(&b, "Using the cached manifest from %s.\n\n", checked.Format(time.RFC1123))
+	}
+
+	fmt.Fprintf(&b, "Server version: %s\n", manifest.Version)
+	if local := loadLocalManifest(); local != nil {
+		fmt.Fprintf(&b, "Local version:  %s\n", local.Version)
+	} else {
+		fmt.Fprintf(&b, "Local version:  (never patched)\n")
+	}
+
+	results := verifyFiles(manifest, true, progress)
+	report := buildRepairReport(manifest, results)
+	fmt.Fprintf(&b, "Files checked:  %d\n", len(results))
+	fmt.Fprintf(&b, "Problems:       %d\n", report.count())
+
+	for _, category := range repairCategories {
+		items := report.Items[category.Name]
+		if len(items) == 0 {
+			continue
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Path < items[j].Path })
+		fmt.Fprintf(&b, "\n%s (%d, %s):\n", category.Name, len(items), category.Description)
+		for _, item := range items {
+			fmt.Fprintf(&b, "  %s (%s)\n", item.Path, formatBytes(item.Size))
+		}
+	}
+	return b.String()
+}
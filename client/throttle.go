@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Largest chunk a throttled read will pass through at once. Keeping reads
+// small lets the limiter react quickly when the cap is changed mid-download.
+const throttleChunkSize = 32 * 1024
+
+// rateLimiter is a token bucket shared by every download, so the combined
+// transfer rate of all concurrent downloads stays under the configured cap.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second, 0 = unlimited
+	tokens float64
+	last   time.Time
+}
+
+// downloadLimiter throttles all patch downloads (max_download_kbps)
+var downloadLimiter = &rateLimiter{}
+
+// SetKbps changes the cap in kilobits per second. 0 disables throttling.
+// It is safe to call while downloads are running.
+func (rl *rateLimiter) SetKbps(kbps int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if kbps <= 0 {
+		rl.rate = 0
+	} else {
+		rl.rate = float64(kbps) * 1000 / 8
+	}
+	rl.tokens = 0
+	rl.last = time.Now()
+}
+
+// Kbps returns the current cap in kilobits per second (0 = unlimited)
+func (rl *rateLimiter) Kbps() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return int(rl.rate * 8 / 1000)
+}
+
+// wait blocks until n bytes may be transferred under the current cap
+func (rl *rateLimiter) wait(n int) {
+	for {
+		rl.mu.Lock()
+		if rl.rate == 0 {
+			rl.mu.Unlock()
+			return
+		}
+
+		// Refill the bucket, allowing at most one second of burst
+		now := time.Now()
+		rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+		if rl.tokens > rl.rate {
+			rl.tokens = rl.rate
+		}
+		rl.last = now
+
+		if rl.tokens >= 0 {
+			rl.tokens -= float64(n)
+			rl.mu.Unlock()
+			return
+		}
+
+		// In debt - sleep a little and re-check, so a new cap applies right away
+		sleep := time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+		rl.mu.Unlock()
+		if sleep > 100*time.Millisecond {
+			sleep = 100 * time.Millisecond
+		}
+		time.Sleep(sleep)
+	}
+}
+
+// throttledReader wraps a download body so reads are paced by a rateLimiter
+type throttledReader struct {
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+func newThrottledReader(r io.Reader) io.Reader {
+	return &throttledReader{r: r, limiter: downloadLimiter}
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunkSize {
+		p = p[:throttleChunkSize]
+	}
+	n, err := tr.r.Read(p)
+	if n > 0 {
+		tr.limiter.wait(n)
+	}
+	return n, err
+}
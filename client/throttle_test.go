@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterKbps(t *testing.T) {
+	tests := []struct {
+		set, want int
+	}{
+		{0, 0},
+		{-5, 0},
+		{8, 8},
+		{1000, 1000},
+		{20000, 20000},
+	}
+	for _, tt := range tests {
+		rl := &rateLimiter{}
+		rl.SetKbps(tt.set)
+		if got := rl.Kbps(); got != tt.want {
+			t.Errorf("SetKbps(%d): Kbps() = %d, want %d", tt.set, got, tt.want)
+		}
+	}
+}
+
+func TestThrottledReader(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 3*throttleChunkSize+100)
+	tests := []struct {
+		name    string
+		kbps    int
+		minTime time.Duration
+	}{
+		{"unlimited", 0, 0},
+		// 200 KB/s, so the 96 KB takes about half a second
+		{"capped", 1600, 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := &rateLimiter{}
+			limiter.SetKbps(tt.kbps)
+			limiter.tokens = 0 // no burst, so the cap shows in a short test
+			r := &throttledReader{r: bytes.NewReader(data), limiter: limiter}
+
+			start := time.Now()
+			buf := make([]byte, 2*throttleChunkSize)
+			n, _ := r.Read(buf)
+			if n > throttleChunkSize {
+				t.Errorf("Read returned %d bytes, more than one chunk", n)
+			}
+			rest, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := n + len(rest); got != len(data) {
+				t.Errorf("read %d bytes, want %d", got, len(data))
+			}
+			if elapsed := time.Since(start); elapsed < tt.minTime {
+				t.Errorf("took %v, want at least %v", elapsed, tt.minTime)
+			}
+		})
+	}
+}
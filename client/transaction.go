@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Updates are applied as a transaction so that an interrupted update (crash,
+// power cut, killed process) never leaves the game folder half old and half
+// new:
+//
+//  1. Every file is downloaded into .patcher-staging/files and checked
+//     against the manifest. Nothing in the game folder is touched yet, so
+//     stopping here just throws the staged files away.
+//  2. A journal listing every change is written to .patcher-staging.
+//  3. Each file being replaced or deleted is moved aside (into the backup
+//     store for stock files, otherwise .patcher-staging/undo) and the staged
+//     file is renamed into place.
+//  4. The new manifest is saved as .patcher-manifest.json and the staging
+//     folder is removed.
+//
+// If a journal is found on startup the commit is replayed; if that fails it
+// is rolled back, so the game folder always matches exactly one manifest.
+const (
+	stagingDir  = ".patcher-staging"
+	journalFile = ".patcher-staging/journal.json"
+
+	// A staging folder with a damaged journal is moved here, with a
+	// timestamp added, so the files it replaced aren't lost
+	damagedStagingDir = ".patcher-staging-damaged"
+)
+
+// errRollbackFailed is wrapped into commit errors after which the game
+// folder could not be put back as it was. The journal is kept, so the next
+// start tries again.
+var errRollbackFailed = errors.New("rollback failed")
+
+// Journal actions
+const (
+	opReplace = "replace"
+	opDelete  = "delete"
+)
+
+// journalOp is one change to the game folder
+type journalOp struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`
+	Backup bool   `json:"backup,omitempty"` // the file being replaced is a stock original for the backup store
+}
+
+// updateJournal is written just before the first file in the game folder is
+// changed
+type updateJournal struct {
+	Version  string      `json:"version"`
+	Ops      []journalOp `json:"ops"`
+	Manifest *Manifest   `json:"manifest"` // saved as the local manifest once committed
+}
+
+// updateTxn stages and commits one update
+type updateTxn struct {
+	manifest *Manifest
+	ops      []journalOp
+}
+
+// beginUpdate starts a new update. An interrupted update is finished or
+// rolled back first, since its undo files are the only copy of what it
+// replaced; if that fails the new update is refused.
+func beginUpdate(manifest *Manifest) (*updateTxn, error) {
+	if _, err := recoverUpdate(); err != nil {
+		return nil, fmt.Errorf("a previous update could not be finished or undone: %v", err)
+	}
+	os.RemoveAll(stagingDir)
+	if err := os.MkdirAll(stagingDir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create %s: %v", stagingDir, err)
+	}
+	return &updateTxn{manifest: manifest}, nil
+}
+
+func stagedPath(path string) string {
+	return filepath.Join(stagingDir, "files", filepath.FromSlash(path))
+}
+
+func undoPath(path string) string {
+	return filepath.Join(stagingDir, "undo", filepath.FromSlash(path))
+}
+
+// stage downloads file into the staging folder and checks it against the
+// manifest
+func (t *updateTxn) stage(file FileEntry) error {
+	start := time.Now()
+	staged := stagedPath(file.Path)
+	err := downloadFileTo(config.ServerURL, file.Path, staged)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(staged)
+	if err != nil {
+		return err
+	}
+	if info.Size() != file.Size {
+		os.Remove(staged)
+		return fmt.Errorf("downloaded file is %d bytes, expected %d", info.Size(), file.Size)
+	}
+	hash, err := calculateMD5(staged)
+	if err != nil || hash != file.MD5 {
+		os.Remove(staged)
+		return fmt.Errorf("downloaded file failed checksum verification")
+	}
+
+	t.ops = append(t.ops, journalOp{Path: filepath.ToSlash(file.Path), Action: opReplace})
+	slog.Info("downloaded", "path", file.Path, "size", file.Size, "duration", time.Since(start))
+	return nil
+}
+
+// abort throws away everything staged so far. The game folder hasn't been
+// changed yet.
+func (t *updateTxn) abort() {
+	os.RemoveAll(stagingDir)
+}
+
+// commit swaps the staged files into place and removes toDelete. On error
+// the game folder is rolled back to how it was before the update.
+func (t *updateTxn) commit(toDelete []string) error {
+	backups := loadBackupIndex()
+	local := loadLocalManifest()
+
+	for i := range t.ops {
+		t.ops[i].Backup = backups.wouldBackUp(t.ops[i].Path, local)
+	}
+	for _, path := range toDelete {
+		t.ops = append(t.ops, journalOp{Path: filepath.ToSlash(path), Action: opDelete})
+	}
+
+	for _, op := range t.ops {
+		slog.Info("update step", "path", op.Path, "action", op.Action, "backup", op.Backup)
+	}
+
+	journal := &updateJournal{Version: t.manifest.Version, Ops: t.ops, Manifest: withKeptObsolete(t.manifest, toDelete)}
+	if err := journal.save(); err != nil {
+		t.abort()
+		return fmt.Errorf("could not write update journal: %v", err)
+	}
+
+	return journal.apply()
+}
+
+// save writes the journal atomically
+func (j *updateJournal) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpFile := journalFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, journalFile)
+}
+
+// apply carries out the journal, rolling back if any step fails. Every step
+// checks what's already on disk, so apply can be run again after an
+// interruption.
+func (j *updateJournal) apply() error {
+	backups := loadBackupIndex()
+	local := loadLocalManifest()
+
+	for _, op := range j.Ops {
+		if err := checkManifestPath(op.Path); err != nil {
+			continue
+		}
+
+		var err error
+		switch op.Action {
+		case opReplace:
+			err = j.replace(op, backups, local)
+		case opDelete:
+			err = moveAside(op.Path)
+		}
+		if err != nil {
+			err = fmt.Errorf("could not update %s: %v", op.Path, err)
+			slog.Error("update failed, rolling back", "version", j.Version, "error", err)
+			if rbErr := j.rollback(); rbErr != nil {
+				return fmt.Errorf("%v (%w: %v)", err, errRollbackFailed, rbErr)
+			}
+			return err
+		}
+	}
+
+	// Committed - the rest can't leave the game folder in a mixed state
+	installed := []string{}
+	for _, op := range j.Ops {
+		if op.Action == opReplace {
+			installed = append(installed, op.Path)
+		}
+	}
+	saveLocalManifest(j.Manifest, installed)
+
+	// Put stock files back where the patch no longer replaces them, and
+	// clear away folders left empty by removed files (e.g. a disabled
+	// content pack)
+	for _, op := range j.Ops {
+		if op.Action != opDelete {
+			continue
+		}
+		if backups.find(op.Path) != nil {
+			if err := backups.putBack(op.Path); err != nil {
+				slog.Warn("could not restore original", "path", op.Path, "error", err)
+			}
+			continue
+		}
+		removeEmptyParents(op.Path)
+	}
+
+	os.RemoveAll(stagingDir)
+	slog.Info("update committed", "version", j.Version, "steps", len(j.Ops))
+	return nil
+}
+
+// replace swaps one staged file into place, moving the current file aside
+// first. Does nothing if it was already done.
+func (j *updateJournal) replace(op journalOp, backups *backupIndex, local *Manifest) error {
+	staged := stagedPath(op.Path)
+	if _, err := os.Stat(staged); err != nil {
+		return nil // Already in place
+	}
+
+	if _, err := os.Stat(op.Path); err == nil {
+		backedUp := false
+		if op.Backup {
+			var err error
+			backedUp, err = backups.backupOriginal(op.Path, j.Version, local)
+			if err != nil {
+				return err
+			}
+		}
+		if !backedUp {
+			if err := moveAside(op.Path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if dir := filepath.Dir(op.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.Rename(staged, op.Path)
+}
+
+// moveAside moves path into the undo folder so it can be put back on
+// rollback. Does nothing if path doesn't exist.
+func moveAside(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	undo := undoPath(path)
+	if err := os.MkdirAll(filepath.Dir(undo), 0755); err != nil {
+		return err
+	}
+	return os.Rename(path, undo)
+}
+
+// removeEmptyParents removes the folders above path, innermost first, for
+// as long as they are empty. The game folder itself is never removed.
+func removeEmptyParents(path string) {
+	for dir := filepath.Dir(filepath.FromSlash(path)); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// rollback undoes every change made by the journal, in reverse order, and
+// clears the staging folder. The local manifest hasn't been replaced yet, so
+// it still describes the old version.
+func (j *updateJournal) rollback() error {
+	backups := loadBackupIndex()
+
+	var firstErr error
+	for i := len(j.Ops) - 1; i >= 0; i-- {
+		op := j.Ops[i]
+		if checkManifestPath(op.Path) != nil {
+			continue
+		}
+
+		err := j.undo(op, backups)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %v", op.Path, err)
+		}
+	}
+
+	if firstErr != nil {
+		// Keep the journal and undo files so the next start can try again
+		return firstErr
+	}
+	os.RemoveAll(stagingDir)
+	return nil
+}
+
+func (j *updateJournal) undo(op journalOp, backups *backupIndex) error {
+	if op.Action == opReplace {
+		// The new file is in place if it's no longer staged
+		if _, err := os.Stat(stagedPath(op.Path)); err != nil {
+			if err := os.Remove(op.Path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	undo := undoPath(op.Path)
+	if _, err := os.Stat(undo); err == nil {
+		return os.Rename(undo, op.Path)
+	}
+
+	// Stock originals were moved into the backup store instead
+	if op.Backup {
+		if entry := backups.find(op.Path); entry != nil && entry.Version == j.Version {
+			return backups.putBack(op.Path)
+		}
+	}
+	return nil
+}
+
+// updatePending reports whether an interrupted update is waiting for
+// recoverUpdate. Only the journal matters: without one the game folder
+// wasn't touched.
+func updatePending() bool {
+	_, err := os.Stat(journalFile)
+	return err == nil
+}
+
+// recoverUpdate finishes or rolls back an update that was interrupted. It
+// returns true if there was one.
+func recoverUpdate() (bool, error) {
+	if _, err := os.Stat(stagingDir); err != nil {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(journalFile)
+	if err != nil {
+		// Interrupted while downloading - the game folder wasn't touched
+		os.RemoveAll(stagingDir)
+		return false, nil
+	}
+
+	var journal updateJournal
+	if err := json.Unmarshal(data, &journal); err != nil || journal.Manifest == nil {
+		// Can't be replayed or rolled back. Keep what it moved aside and
+		// let Verify & Repair download the patch files again.
+		damaged := damagedStagingDir + "-" + time.Now().Format("20060102-150405")
+		if err := os.Rename(stagingDir, damaged); err != nil {
+			return true, fmt.Errorf("update journal %s is damaged and could not be moved aside: %v", journalFile, err)
+		}
+		slog.Error("damaged update journal moved aside", "path", damaged)
+		return true, fmt.Errorf("update journal %s was damaged; the files it replaced are in %s. Run Verify & Repair (patcher repair) to fix the game files", journalFile, damaged)
+	}
+
+	slog.Info("resuming interrupted update", "version", journal.Version)
+	if err := journal.apply(); err != nil {
+		if _, statErr := os.Stat(stagingDir); statErr != nil {
+			// Rolled back cleanly - the old version is intact
+			slog.Warn("rolled back interrupted update", "error", err)
+			return true, nil
+		}
+		return true, err
+	}
+	return true, nil
+}
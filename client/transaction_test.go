@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// inTempGameFolder runs the test from an empty game folder, with backups
+// turned off
+func inTempGameFolder(t *testing.T) {
+	t.Helper()
+	old, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	oldConfig := config
+	config = &Config{MaxBackupMB: -1}
+	t.Cleanup(func() {
+		os.Chdir(old)
+		config = oldConfig
+	})
+}
+
+// writeFiles creates each path with its contents
+func writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	for path, contents := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkGameFolder compares the game folder with want; "" means the file
+// must not exist
+func checkGameFolder(t *testing.T, want map[string]string) {
+	t.Helper()
+	for path, contents := range want {
+		data, err := os.ReadFile(path)
+		switch {
+		case contents == "" && err == nil:
+			t.Errorf("%s exists, want it gone", path)
+		case contents == "":
+		case err != nil:
+			t.Errorf("%s: %v", path, err)
+		case string(data) != contents:
+			t.Errorf("%s = %q, want %q", path, data, contents)
+		}
+	}
+}
+
+func TestJournalApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  map[string]string // game folder and staging folder
+		ops     []journalOp
+		wantErr bool
+		after   map[string]string
+	}{
+		{
+			name: "commit",
+			before: map[string]string{
+				"a.txt":                          "old a",
+				"b.txt":                          "old b",
+				".patcher-staging/files/a.txt":   "new a",
+				".patcher-staging/files/c/c.txt": "new c",
+			},
+			ops: []journalOp{
+				{Path: "a.txt", Action: opReplace},
+				{Path: "c/c.txt", Action: opReplace},
+				{Path: "b.txt", Action: opDelete},
+			},
+			after: map[string]string{"a.txt": "new a", "b.txt": "", "c/c.txt": "new c"},
+		},
+		{
+			name: "resume after some steps were done",
+			before: map[string]string{
+				"a.txt":                        "new a",
+				".patcher-staging/undo/a.txt":  "old a",
+				"b.txt":                        "old b",
+				".patcher-staging/files/b.txt": "new b",
+			},
+			ops: []journalOp{
+				{Path: "a.txt", Action: opReplace},
+				{Path: "b.txt", Action: opReplace},
+			},
+			after: map[string]string{"a.txt": "new a", "b.txt": "new b"},
+		},
+		{
+			name: "failed step rolls back",
+			before: map[string]string{
+				"a.txt":                        "old a",
+				"b.txt":                        "old b",
+				"d":                            "a file where a folder is needed",
+				".patcher-staging/files/a.txt": "new a",
+				".patcher-staging/files/c.txt": "new c",
+				".patcher-staging/files/d/x":   "new x",
+			},
+			ops: []journalOp{
+				{Path: "a.txt", Action: opReplace},
+				{Path: "b.txt", Action: opDelete},
+				{Path: "c.txt", Action: opReplace},
+				{Path: "d/x", Action: opReplace},
+			},
+			wantErr: true,
+			after:   map[string]string{"a.txt": "old a", "b.txt": "old b", "c.txt": "", "d": "a file where a folder is needed"},
+		},
+		{
+			name: "unsafe paths are skipped",
+			before: map[string]string{
+				".patcher-staging/files/a.txt": "new a",
+			},
+			ops: []journalOp{
+				{Path: "../outside.txt", Action: opDelete},
+				{Path: "a.txt", Action: opReplace},
+			},
+			after: map[string]string{"a.txt": "new a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inTempGameFolder(t)
+			writeFiles(t, tt.before)
+
+			manifest := &Manifest{Version: "2"}
+			journal := &updateJournal{Version: "2", Ops: tt.ops, Manifest: manifest}
+			if err := journal.save(); err != nil {
+				t.Fatal(err)
+			}
+			err := journal.apply()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("apply() = %v, want error %v", err, tt.wantErr)
+			}
+			if errors.Is(err, errRollbackFailed) {
+				t.Errorf("apply() = %v, want a clean rollback", err)
+			}
+			checkGameFolder(t, tt.after)
+
+			if _, err := os.Stat(stagingDir); err == nil {
+				t.Errorf("%s still exists", stagingDir)
+			}
+			local := loadLocalManifest()
+			if committed := local != nil && local.Version == "2"; committed == tt.wantErr {
+				t.Errorf("local manifest = %+v, want it saved only on success", local)
+			}
+		})
+	}
+}
+
+func TestRecoverUpdate(t *testing.T) {
+	tests := []struct {
+		name        string
+		before      map[string]string
+		wantFound   bool
+		wantErr     bool
+		wantDamaged bool
+		after       map[string]string
+	}{
+		{
+			name:   "nothing to recover",
+			before: map[string]string{"a.txt": "old a"},
+			after:  map[string]string{"a.txt": "old a"},
+		},
+		{
+			name: "interrupted while downloading",
+			before: map[string]string{
+				"a.txt":                        "old a",
+				".patcher-staging/files/a.txt": "new a",
+			},
+			after: map[string]string{"a.txt": "old a"},
+		},
+		{
+			name: "interrupted while committing",
+			before: map[string]string{
+				"a.txt":                        "new a",
+				".patcher-staging/undo/a.txt":  "old a",
+				".patcher-staging/files/b.txt": "new b",
+				journalFile: `{"version": "2", "manifest": {"version": "2", "files": []},
+					"ops": [{"path": "a.txt", "action": "replace"}, {"path": "b.txt", "action": "replace"}]}`,
+			},
+			wantFound: true,
+			after:     map[string]string{"a.txt": "new a", "b.txt": "new b"},
+		},
+		{
+			name: "damaged journal",
+			before: map[string]string{
+				"a.txt":                       "new a",
+				".patcher-staging/undo/a.txt": "old a",
+				journalFile:                   `{"version": "2", "ops": [`,
+			},
+			wantFound:   true,
+			wantErr:     true,
+			wantDamaged: true,
+			after:       map[string]string{"a.txt": "new a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inTempGameFolder(t)
+			writeFiles(t, tt.before)
+
+			found, err := recoverUpdate()
+			if found != tt.wantFound || (err != nil) != tt.wantErr {
+				t.Fatalf("recoverUpdate() = %v, %v; want %v, error %v", found, err, tt.wantFound, tt.wantErr)
+			}
+			checkGameFolder(t, tt.after)
+
+			if _, err := os.Stat(stagingDir); err == nil {
+				t.Errorf("%s still exists", stagingDir)
+			}
+			damaged, _ := filepath.Glob(damagedStagingDir + "-*/undo/a.txt")
+			if (len(damaged) == 1) != tt.wantDamaged {
+				t.Errorf("moved aside: %v, want %v", damaged, tt.wantDamaged)
+			}
+		})
+	}
+}
+
+func TestBeginUpdateKeepsDamagedJournal(t *testing.T) {
+	inTempGameFolder(t)
+	writeFiles(t, map[string]string{
+		".patcher-staging/undo/a.txt": "old a",
+		journalFile:                   "not json",
+	})
+
+	if _, err := beginUpdate(&Manifest{Version: "3"}); err == nil {
+		t.Fatal("beginUpdate() succeeded over a damaged journal")
+	}
+	damaged, _ := filepath.Glob(damagedStagingDir + "-*/undo/a.txt")
+	if len(damaged) != 1 {
+		t.Errorf("undo files were not kept: %v", damaged)
+	}
+}
+
+func TestReadOnlyCommandsLeavePendingUpdate(t *testing.T) {
+	journal := `{"version": "2", "manifest": {"version": "2", "files": []}, "ops": []}`
+	for _, command := range []string{"verify", "status"} {
+		t.Run(command, func(t *testing.T) {
+			inTempGameFolder(t)
+			writeFiles(t, map[string]string{
+				configFile:  `{"server_url": "http://127.0.0.1:1/patches", "game_exe": "eqgame.exe"}`,
+				journalFile: journal,
+			})
+
+			if code := run([]string{command}, false); code != exitPending {
+				t.Errorf("run(%s) = %d, want %d", command, code, exitPending)
+			}
+			checkGameFolder(t, map[string]string{journalFile: journal})
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Results of checking a file against the manifest
+const (
+	statusOK           = "ok"
+	statusMissing      = "missing"
+	statusSizeMismatch = "size-mismatch"
+	statusHashMismatch = "hash-mismatch"
+)
+
+// FileStatus is the result of checking one manifest file on disk
+type FileStatus struct {
+	File      FileEntry `json:"-"`
+	Path      string    `json:"path"`
+	Status    string    `json:"status"`
+	Size      int64     `json:"size"`       // size in the manifest
+	LocalSize int64     `json:"local_size"` // size on disk, -1 if missing
+}
+
+// verifyFiles checks every manifest file on disk. Hashes come from the
+// verification cache when a file's size and mtime are unchanged, unless full
+// is set. progress, if not nil, is called after each file is checked.
+// Launcher binaries are skipped; they are replaced by the self-updater.
+func verifyFiles(manifest *Manifest, full bool, progress func(done, total int)) []FileStatus {
+	cache := loadVerifyCache()
+	results := checkFiles(manifest, full, cache, progress)
+	cache.prune(manifest)
+	cache.save()
+	return results
+}
+
+// verifyFilesReadOnly is verifyFiles for commands that must not change the
+// game folder: the verification cache is read but not saved
+func verifyFilesReadOnly(manifest *Manifest, full bool, progress func(done, total int)) []FileStatus {
+	return checkFiles(manifest, full, loadVerifyCache(), progress)
+}
+
+// checkFiles checks every manifest file on disk, updating cache in memory
+func checkFiles(manifest *Manifest, full bool, cache *verifyCache, progress func(done, total int)) []FileStatus {
+	start := time.Now()
+
+	results := []FileStatus{}
+	for i, file := range manifest.Files {
+		if progress != nil {
+			progress(i+1, len(manifest.Files))
+		}
+
+		if isLauncherBinary(file.Path) {
+			continue
+		}
+
+		result := FileStatus{
+			File:      file,
+			Path:      file.Path,
+			Status:    statusOK,
+			Size:      file.Size,
+			LocalSize: -1,
+		}
+
+		// Check if file exists
+		info, err := os.Stat(file.Path)
+		if err != nil {
+			cache.forget(file.Path)
+			result.Status = statusMissing
+			results = append(results, result)
+			continue
+		}
+		result.LocalSize = info.Size()
+
+		// Check size and hash
+		if info.Size() != file.Size {
+			result.Status = statusSizeMismatch
+		} else if localMD5, err := cache.fileMD5(file.Path, info, full); err != nil || localMD5 != file.MD5 {
+			result.Status = statusHashMismatch
+		}
+		results = append(results, result)
+	}
+
+	problems := 0
+	for _, result := range results {
+		if result.Status == statusOK {
+			slog.Debug("verified", "path", result.Path)
+		} else {
+			problems++
+			slog.Info("needs repair", "path", result.Path, "status", result.Status, "size", result.Size, "local_size", result.LocalSize)
+		}
+	}
+	slog.Info("verification finished", "files", len(results), "problems", problems, "full", full, "duration", time.Since(start))
+
+	return results
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const verifyCacheFile = ".patcher-verify-cache.json"
+
+// cachedFile records what a file looked like when we last hashed it
+type cachedFile struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"` // UnixNano
+	MD5     string `json:"md5"`
+}
+
+// verifyCache remembers the hash of every file we've verified, keyed by
+// path. If a file's size and modification time haven't changed since it was
+// hashed, the cached hash is trusted instead of reading the whole file again.
+type verifyCache struct {
+	mu    sync.Mutex
+	Files map[string]cachedFile `json:"files"`
+}
+
+// loadVerifyCache loads the cache from disk, returning an empty cache if it
+// doesn't exist or can't be read
+func loadVerifyCache() *verifyCache {
+	vc := &verifyCache{Files: make(map[string]cachedFile)}
+
+	data, err := os.ReadFile(verifyCacheFile)
+	if err != nil {
+		return vc
+	}
+	if err := json.Unmarshal(data, vc); err != nil || vc.Files == nil {
+		vc.Files = make(map[string]cachedFile)
+	}
+
+	return vc
+}
+
+// fileMD5 returns the MD5 of path, using the cached value when the file's
+// size and mtime match what we recorded. Set full to always rehash.
+func (vc *verifyCache) fileMD5(path string, info os.FileInfo, full bool) (string, error) {
+	key := filepath.ToSlash(path)
+
+	vc.mu.Lock()
+	cached, ok := vc.Files[key]
+	vc.mu.Unlock()
+
+	if ok && !full && cached.Size == info.Size() && cached.ModTime == info.ModTime().UnixNano() {
+		return cached.MD5, nil
+	}
+
+	hash, err := calculateMD5(path)
+	if err != nil {
+		vc.forget(path)
+		return "", err
+	}
+
+	vc.mu.Lock()
+	vc.Files[key] = cachedFile{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		MD5:     hash,
+	}
+	vc.mu.Unlock()
+
+	return hash, nil
+}
+
+// forget drops the cached entry for path so it is rehashed next time
+func (vc *verifyCache) forget(path string) {
+	vc.mu.Lock()
+	delete(vc.Files, filepath.ToSlash(path))
+	vc.mu.Unlock()
+}
+
+// prune removes entries for files that are no longer in the manifest
+func (vc *verifyCache) prune(manifest *Manifest) {
+	inManifest := make(map[string]bool)
+	for _, file := range manifest.Files {
+		inManifest[filepath.ToSlash(file.Path)] = true
+	}
+
+	vc.mu.Lock()
+	for key := range vc.Files {
+		if !inManifest[key] {
+			delete(vc.Files, key)
+		}
+	}
+	vc.mu.Unlock()
+}
+
+// save writes the cache back to disk
+func (vc *verifyCache) save() error {
+	vc.mu.Lock()
+	data, err := json.MarshalIndent(vc, "", "  ")
+	vc.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(verifyCacheFile, data, 0644)
+}
@@ -12,6 +12,16 @@ type ManifestFile struct {
 	Path string `json:"path"`
 	MD5  string `json:"md5"`
 	Size int64  `json:"size"`
+	Pack string `json:"pack,omitempty"` // optional content pack
+}
+
+// ManifestPack represents an optional content pack (from packs.json)
+type ManifestPack struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Size        int64  `json:"size"`
+	Files       int    `json:"files"`
+	Default     bool   `json:"default,omitempty"`
 }
 
 // Manifest represents the patch manifest structure
@@ -19,6 +29,8 @@ type Manifest struct {
 	Version   string          `json:"version"`
 	Generated string          `json:"generated,omitempty"`
 	Files     []*ManifestFile `json:"files"`
+	Launcher  []*ManifestFile `json:"launcher,omitempty"` // LaunchPad.exe/patcher.exe for self-update
+	Packs     []*ManifestPack `json:"packs,omitempty"`    // optional content packs
 }
 
 // ManifestManager handles manifest operations
@@ -118,6 +130,17 @@ func (mm *ManifestManager) GetManifestSummary() string {
 		summary += fmt.Sprintf("  %s: %d files\n", folder, count)
 	}
 
+	if len(mm.manifest.Packs) > 0 {
+		summary += "\nContent Packs:\n"
+		for _, pack := range mm.manifest.Packs {
+			state := "off by default"
+			if pack.Default {
+				state = "on by default"
+			}
+			summary += fmt.Sprintf("  %s: %d files, %s (%s)\n", pack.Name, pack.Files, FormatFileSize(pack.Size), state)
+		}
+	}
+
 	return summary
 }
 
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// crash-intake receives the crash reports LaunchPad uploads (with the
+// player's consent) and keeps them on disk for the server team. Put it
+// behind nginx and publish its URL as crash_report_url in
+// server-config.json.
+
+// Settings, from the command line
+var (
+	listenAddr    = flag.String("listen", "127.0.0.1:8787", "address to listen on")
+	reportDir     = flag.String("dir", "/var/lib/eq-crash-reports", "folder to store reports in")
+	maxReportMB   = flag.Int64("max-report-mb", 10, "largest report accepted, in MB")
+	keepDays      = flag.Int("keep-days", 30, "delete reports older than this")
+	keepReports   = flag.Int("keep-reports", 2000, "most reports kept; the oldest go first")
+	keepTotalMB   = flag.Int64("keep-total-mb", 2048, "most disk space used by reports, in MB")
+	perHourPerIP  = flag.Int("per-hour", 10, "most reports accepted from one address per hour")
+	trustProxyHdr = flag.Bool("behind-proxy", false, "use X-Real-IP, or the last X-Forwarded-For hop, from the reverse proxy for rate limiting")
+)
+
+// storeMu makes room for a report and stores it in one step, so two uploads
+// can't both fit into the same free space
+var storeMu sync.Mutex
+
+// reportInfo is the report.json every upload must contain
+type reportInfo struct {
+	Created         string `json:"created"`
+	ExitCode        string `json:"exit_code"`
+	ManifestVersion string `json:"manifest_version"`
+}
+
+func main() {
+	flag.Parse()
+
+	if err := os.MkdirAll(*reportDir, 0750); err != nil {
+		log.Fatalf("Could not create %s: %v", *reportDir, err)
+	}
+
+	// Apply the retention policy now and then every hour
+	go func() {
+		for {
+			storeMu.Lock()
+			pruneReports(0)
+			storeMu.Unlock()
+			time.Sleep(time.Hour)
+		}
+	}()
+
+	limiter := &rateLimiter{perHour: *perHourPerIP, seen: make(map[string][]time.Time)}
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		handleReport(w, r, limiter)
+	})
+
+	log.Printf("crash-intake listening on %s, storing reports in %s", *listenAddr, *reportDir)
+	log.Fatal(http.ListenAndServe(*listenAddr, nil))
+}
+
+// handleReport stores one uploaded report: a zip containing report.json
+func handleReport(w http.ResponseWriter, r *http.Request, limiter *rateLimiter) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "POST a crash report", http.StatusMethodNotAllowed)
+		return
+	}
+
+	client := clientAddr(r)
+	if !limiter.allow(client) {
+		http.Error(w, "too many reports, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	maxBytes := *maxReportMB * 1024 * 1024
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("report too large (limit %d MB)", *maxReportMB), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		// The upload broke off, not a size problem
+		http.Error(w, "could not read report", http.StatusBadRequest)
+		return
+	}
+
+	info, err := checkReport(body)
+	if err != nil {
+		log.Printf("Rejected report from %s: %v", client, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	// Make room first, so keep-total-mb holds between the hourly runs
+	if total := pruneReports(int64(len(body))); total+int64(len(body)) > *keepTotalMB*1024*1024 {
+		log.Printf("Rejected report from %s: no room under -keep-total-mb", client)
+		http.Error(w, "no room for more reports, try again later", http.StatusInsufficientStorage)
+		return
+	}
+
+	id, err := newReportID()
+	if err != nil {
+		http.Error(w, "could not store report", http.StatusInternalServerError)
+		return
+	}
+	path := filepath.Join(*reportDir, id+".zip")
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, body, 0640); err != nil {
+		log.Printf("Could not write %s: %v", tmpFile, err)
+		http.Error(w, "could not store report", http.StatusInternalServerError)
+		return
+	}
+	if err := os.Rename(tmpFile, path); err != nil {
+		os.Remove(tmpFile)
+		http.Error(w, "could not store report", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Stored report %s from %s (%d bytes, exit code %s, version %s)", id, client, len(body), info.ExitCode, info.ManifestVersion)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"id": id})
+}
+
+// checkReport makes sure the upload is a zip with a readable report.json
+func checkReport(body []byte) (*reportInfo, error) {
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, fmt.Errorf("not a zip file")
+	}
+	for _, file := range zr.File {
+		if file.Name != "report.json" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("report.json is damaged")
+		}
+		defer rc.Close()
+		var info reportInfo
+		if err := json.NewDecoder(io.LimitReader(rc, 1024*1024)).Decode(&info); err != nil {
+			return nil, fmt.Errorf("report.json is not valid JSON")
+		}
+		return &info, nil
+	}
+	return nil, fmt.Errorf("report.json missing")
+}
+
+// newReportID names a report by upload time plus a random suffix, so
+// reports sort by age
+func newReportID() (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix), nil
+}
+
+// clientAddr is the uploader's IP address, as seen by the reverse proxy
+func clientAddr(r *http.Request) string {
+	if *trustProxyHdr {
+		if ip := r.Header.Get("X-Real-IP"); ip != "" {
+			return ip
+		}
+		// Earlier hops come from the client and can be made up; the last
+		// one was added by our proxy
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			hops := strings.Split(forwarded, ",")
+			return strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimiter allows each address a number of reports per hour, so one
+// crash loop can't fill the disk
+type rateLimiter struct {
+	mu      sync.Mutex
+	perHour int
+	seen    map[string][]time.Time
+}
+
+func (l *rateLimiter) allow(addr string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := time.Now().Add(-time.Hour)
+	recent := []time.Time{}
+	for _, t := range l.seen[addr] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= l.perHour {
+		l.seen[addr] = recent
+		return false
+	}
+	l.seen[addr] = append(recent, time.Now())
+
+	// Forget addresses that have gone quiet
+	for other, times := range l.seen {
+		if len(times) > 0 && times[len(times)-1].Before(cutoff) {
+			delete(l.seen, other)
+		}
+	}
+	return true
+}
+
+// pruneReports applies the retention policy: reports older than keep-days
+// go, then the oldest until both keep-reports and keep-total-mb are met with
+// room for one more report of incoming bytes (0 = none). It returns the
+// total size of the reports left.
+func pruneReports(incoming int64) int64 {
+	entries, err := os.ReadDir(*reportDir)
+	if err != nil {
+		log.Printf("Could not read %s: %v", *reportDir, err)
+		return 0
+	}
+
+	type report struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	reports := []report{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".zip" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		reports = append(reports, report{filepath.Join(*reportDir, entry.Name()), info.Size(), info.ModTime()})
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].modTime.Before(reports[j].modTime) })
+
+	var total int64
+	for _, r := range reports {
+		total += r.size
+	}
+
+	maxReports := *keepReports
+	if incoming > 0 {
+		maxReports--
+	}
+	cutoff := time.Now().AddDate(0, 0, -*keepDays)
+	maxTotal := *keepTotalMB*1024*1024 - incoming
+	removed := 0
+	for i, r := range reports {
+		remaining := len(reports) - i
+		if !r.modTime.Before(cutoff) && remaining <= maxReports && total <= maxTotal {
+			break
+		}
+		if err := os.Remove(r.path); err != nil {
+			log.Printf("Could not delete %s: %v", r.path, err)
+			continue
+		}
+		total -= r.size
+		removed++
+	}
+	if removed > 0 {
+		log.Printf("Deleted %d old report(s)", removed)
+	}
+	return total
+}
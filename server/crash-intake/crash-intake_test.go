@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientAddr(t *testing.T) {
+	tests := []struct {
+		name        string
+		behindProxy bool
+		realIP      string
+		forwarded   string
+		want        string
+	}{
+		{name: "direct", want: "192.0.2.1"},
+		{name: "headers ignored when not behind a proxy", realIP: "198.51.100.7", forwarded: "198.51.100.8", want: "192.0.2.1"},
+		{name: "X-Real-IP", behindProxy: true, realIP: "198.51.100.7", forwarded: "198.51.100.8", want: "198.51.100.7"},
+		{name: "single hop", behindProxy: true, forwarded: "198.51.100.8", want: "198.51.100.8"},
+		{name: "forged first hop", behindProxy: true, forwarded: "10.0.0.1, 203.0.113.5 ,198.51.100.8", want: "198.51.100.8"},
+		{name: "no headers", behindProxy: true, want: "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		*trustProxyHdr = tt.behindProxy
+		r := httptest.NewRequest("POST", "/", nil)
+		r.RemoteAddr = "192.0.2.1:40000"
+		if tt.realIP != "" {
+			r.Header.Set("X-Real-IP", tt.realIP)
+		}
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := clientAddr(r); got != tt.want {
+			t.Errorf("%s: clientAddr() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	*trustProxyHdr = false
+}
+
+// brokenBody fails partway through, like an upload that is cut off
+type brokenBody struct{ sent bool }
+
+func (b *brokenBody) Read(p []byte) (int, error) {
+	if b.sent {
+		return 0, errors.New("connection reset")
+	}
+	b.sent = true
+	return copy(p, "PK"), nil
+}
+
+func TestHandleReportReadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body io.Reader
+		want int
+	}{
+		{name: "too large", body: strings.NewReader(strings.Repeat("x", 1024*1024+1)), want: http.StatusRequestEntityTooLarge},
+		{name: "upload cut off", body: &brokenBody{}, want: http.StatusBadRequest},
+	}
+	oldMax := *maxReportMB
+	*maxReportMB = 1
+	defer func() { *maxReportMB = oldMax }()
+	for _, tt := range tests {
+		limiter := &rateLimiter{perHour: 10, seen: make(map[string][]time.Time)}
+		w := httptest.NewRecorder()
+		handleReport(w, httptest.NewRequest("POST", "/", tt.body), limiter)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
+
+func TestPruneReports(t *testing.T) {
+	const mb = 1024 * 1024
+	tests := []struct {
+		name      string
+		sizes     []int // oldest first
+		oldDays   int   // age of the oldest report
+		keep      int
+		keepMB    int64
+		incoming  int64
+		wantLeft  int
+		wantTotal int64
+	}{
+		{name: "under every limit", sizes: []int{mb, mb}, keep: 10, keepMB: 5, wantLeft: 2, wantTotal: 2 * mb},
+		{name: "too many", sizes: []int{1, 1, 1}, keep: 2, keepMB: 5, wantLeft: 2, wantTotal: 2},
+		{name: "too large", sizes: []int{mb, mb, mb}, keep: 10, keepMB: 2, wantLeft: 2, wantTotal: 2 * mb},
+		{name: "too old", sizes: []int{1, 1}, oldDays: 40, keep: 10, keepMB: 5, wantLeft: 1, wantTotal: 1},
+		{name: "room for an upload", sizes: []int{mb, mb}, keep: 10, keepMB: 2, incoming: mb, wantLeft: 1, wantTotal: mb},
+		{name: "count for an upload", sizes: []int{1, 1}, keep: 2, keepMB: 5, incoming: 1, wantLeft: 1, wantTotal: 1},
+		{name: "upload larger than the cap", sizes: []int{1}, keep: 10, keepMB: 1, incoming: 2 * mb, wantLeft: 0, wantTotal: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*reportDir = t.TempDir()
+			*keepDays = 30
+			*keepReports = tt.keep
+			*keepTotalMB = tt.keepMB
+
+			now := time.Now()
+			for i, size := range tt.sizes {
+				path := filepath.Join(*reportDir, string(rune('a'+i))+".zip")
+				if err := os.WriteFile(path, make([]byte, size), 0640); err != nil {
+					t.Fatal(err)
+				}
+				modTime := now.Add(time.Duration(i-len(tt.sizes)) * time.Minute)
+				if i == 0 && tt.oldDays > 0 {
+					modTime = now.AddDate(0, 0, -tt.oldDays)
+				}
+				os.Chtimes(path, modTime, modTime)
+			}
+
+			total := pruneReports(tt.incoming)
+			left, _ := filepath.Glob(filepath.Join(*reportDir, "*.zip"))
+			if len(left) != tt.wantLeft || total != tt.wantTotal {
+				t.Errorf("pruneReports(%d) left %d reports, %d bytes; want %d, %d", tt.incoming, len(left), total, tt.wantLeft, tt.wantTotal)
+			}
+			// The newest reports are the ones kept
+			for _, path := range left {
+				if filepath.Base(path) < string(rune('a'+len(tt.sizes)-tt.wantLeft)) {
+					t.Errorf("kept %s, an older report", filepath.Base(path))
+				}
+			}
+		})
+	}
+}
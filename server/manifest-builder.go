@@ -6,18 +6,48 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 )
 
 type FileEntry struct {
 	Path string `json:"path"`
 	MD5  string `json:"md5"`
 	Size int64  `json:"size"`
+	Pack string `json:"pack,omitempty"` // optional content pack, see packs.json
 }
 
 type Manifest struct {
-	Version string      `json:"version"`
-	Files   []FileEntry `json:"files"`
+	Version  string        `json:"version"`
+	Files    []FileEntry   `json:"files"`
+	Launcher []FileEntry   `json:"launcher,omitempty"` // LaunchPad.exe/patcher.exe, for self-update
+	Packs    []ContentPack `json:"packs,omitempty"`    // optional content players can turn on
+}
+
+// ContentPack is a pack as listed in the manifest, with its size worked out
+type ContentPack struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Size        int64  `json:"size"`
+	Files       int    `json:"files"`
+	Default     bool   `json:"default,omitempty"`
+}
+
+// PackDefinition is one entry in packs.json, which lists the optional
+// content packs and which files make them up
+type PackDefinition struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Paths       []string `json:"paths"`   // "folder/" for everything under it, or a pattern like "*.mp3" or "maps/*_hd.txt"
+	Default     bool     `json:"default"` // on unless the player turns it off
+}
+
+// Launcher binaries are published separately from the game files. Clients
+// replace these with a self-update instead of the normal patch process.
+var launcherBinaries = map[string]bool{
+	"LaunchPad.exe": true,
+	"patcher.exe":   true,
 }
 
 func main() {
@@ -37,19 +67,56 @@ func main() {
 
 	fmt.Printf("Scanning directory: %s\n", rootDir)
 
+	packDefs, err := loadPackDefinitions(filepath.Join(rootDir, "packs.json"))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	themeImages := loadThemeImages(filepath.Join(rootDir, "theme.json"))
+	packs := make([]ContentPack, len(packDefs))
+	for i, def := range packDefs {
+		packs[i] = ContentPack{Name: def.Name, Description: def.Description, Default: def.Default}
+	}
+
 	manifest := Manifest{
 		Version: "1.0",
 		Files:   []FileEntry{},
 	}
 
 	// Walk directory tree
-	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+	err = filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
+		// Calculate relative path
+		relPath, err := filepath.Rel(rootDir, path)
+		if err != nil {
+			return err
+		}
+
+		// Convert to forward slashes for cross-platform compatibility
+		relPath = filepath.ToSlash(relPath)
+
+		// Launcher binaries at the top level go in the launcher section
+		// (they can't be patched like game files while running)
+		if !info.IsDir() && launcherBinaries[relPath] {
+			hash, err := calculateMD5(path)
+			if err != nil {
+				fmt.Printf("Warning: Could not hash %s: %v\n", relPath, err)
+				return nil
+			}
+			manifest.Launcher = append(manifest.Launcher, FileEntry{
+				Path: relPath,
+				MD5:  hash,
+				Size: info.Size(),
+			})
+			fmt.Printf("  Launcher: %s (%d bytes, md5: %s)\n", relPath, info.Size(), hash[:8])
+			return nil
+		}
+
 		// Skip directories, manifest, update script, README, and launcher files
-		// Launcher files should NOT be in manifest (can't update themselves while running)
+		// Launcher files should NOT be in the game file list (can't update themselves while running)
 		baseName := filepath.Base(path)
 		if info.IsDir() ||
 		   baseName == "manifest.json" ||
@@ -61,19 +128,14 @@ func main() {
 		   baseName == "patcher-config.json" ||
 		   baseName == "manager.exe" ||
 		   baseName == "news.json" ||
+		   baseName == "server-config.json" ||
+		   baseName == "packs.json" ||
+		   baseName == "theme.json" ||
+		   themeImages[relPath] ||
 		   baseName == "eq-patcher-client.zip" {
 			return nil
 		}
 
-		// Calculate relative path
-		relPath, err := filepath.Rel(rootDir, path)
-		if err != nil {
-			return err
-		}
-
-		// Convert to forward slashes for cross-platform compatibility
-		relPath = filepath.ToSlash(relPath)
-
 		// Calculate MD5
 		hash, err := calculateMD5(path)
 		if err != nil {
@@ -86,9 +148,18 @@ func main() {
 			MD5:  hash,
 			Size: info.Size(),
 		}
+		if i := packFor(relPath, packDefs); i >= 0 {
+			entry.Pack = packs[i].Name
+			packs[i].Size += entry.Size
+			packs[i].Files++
+		}
 
 		manifest.Files = append(manifest.Files, entry)
-		fmt.Printf("  Added: %s (%d bytes, md5: %s)\n", relPath, info.Size(), hash[:8])
+		if entry.Pack != "" {
+			fmt.Printf("  Added: %s (%d bytes, md5: %s, pack: %s)\n", relPath, info.Size(), hash[:8], entry.Pack)
+		} else {
+			fmt.Printf("  Added: %s (%d bytes, md5: %s)\n", relPath, info.Size(), hash[:8])
+		}
 
 		return nil
 	})
@@ -98,6 +169,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	for _, pack := range packs {
+		if pack.Files == 0 {
+			fmt.Printf("Warning: Content pack %q matches no files\n", pack.Name)
+		}
+	}
+	manifest.Packs = packs
+
 	// Write manifest to file
 	manifestPath := filepath.Join(rootDir, "manifest.json")
 	data, err := json.MarshalIndent(manifest, "", "  ")
@@ -114,6 +192,103 @@ func main() {
 
 	fmt.Printf("\n✓ Manifest created: %s\n", manifestPath)
 	fmt.Printf("✓ Total files: %d\n", len(manifest.Files))
+	if len(manifest.Launcher) > 0 {
+		fmt.Printf("✓ Launcher files: %d\n", len(manifest.Launcher))
+	}
+	for _, pack := range manifest.Packs {
+		fmt.Printf("✓ Content pack %q: %d files, %d bytes\n", pack.Name, pack.Files, pack.Size)
+	}
+}
+
+// loadPackDefinitions reads packs.json. No file means no packs.
+func loadPackDefinitions(filePath string) ([]PackDefinition, error) {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Packs []PackDefinition `json:"packs"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid packs.json: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, def := range file.Packs {
+		if def.Name == "" {
+			return nil, fmt.Errorf("packs.json: every pack needs a name")
+		}
+		if seen[def.Name] {
+			return nil, fmt.Errorf("packs.json: pack %q is listed twice", def.Name)
+		}
+		seen[def.Name] = true
+		for _, pattern := range def.Paths {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("packs.json: pack %q has an invalid path %q", def.Name, pattern)
+			}
+		}
+	}
+	return file.Packs, nil
+}
+
+// loadThemeImages returns the images theme.json points at inside the patch
+// folder. They are for LaunchPad, not the game, so they stay out of the
+// manifest.
+func loadThemeImages(filePath string) map[string]bool {
+	images := map[string]bool{}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return images
+	}
+
+	var theme struct {
+		Background string `json:"background"`
+		Logo       string `json:"logo"`
+	}
+	if err := json.Unmarshal(data, &theme); err != nil {
+		fmt.Printf("Warning: Could not read theme.json: %v\n", err)
+		return images
+	}
+	for _, ref := range []string{theme.Background, theme.Logo} {
+		if ref != "" && !strings.Contains(ref, "://") {
+			images[path.Clean(strings.TrimPrefix(filepath.ToSlash(ref), "/"))] = true
+		}
+	}
+	return images
+}
+
+// packFor returns the index of the first pack relPath belongs to, or -1
+func packFor(relPath string, defs []PackDefinition) int {
+	for i, def := range defs {
+		for _, pattern := range def.Paths {
+			if matchPackPath(pattern, relPath) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// matchPackPath matches a packs.json path against a manifest path, ignoring
+// case like Windows does. "folder/" matches everything under folder, a
+// pattern without a slash matches file names anywhere, and anything else
+// matches the whole path.
+func matchPackPath(pattern, relPath string) bool {
+	pattern = strings.ToLower(strings.TrimPrefix(filepath.ToSlash(pattern), "/"))
+	relPath = strings.ToLower(relPath)
+
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(relPath, pattern)
+	}
+	if !strings.Contains(pattern, "/") {
+		relPath = path.Base(relPath)
+	}
+	matched, _ := path.Match(pattern, relPath)
+	return matched
 }
 
 func calculateMD5(filePath string) (string, error) {